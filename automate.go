@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// automateRule moves the cards matching When, a -filter value, to a column.
+// Then is the action, "move <column>"
+type automateRule struct {
+	Name string   `json:"name,omitempty"`
+	When string   `json:"when"`
+	From []string `json:"from,omitempty"` // only cards in these columns
+	Then string   `json:"then"`
+}
+
+// automateFile is the rules file
+type automateFile struct {
+	Rules []automateRule `json:"rules"`
+}
+
+// automateMove is a planned card move and the rule behind it
+type automateMove struct {
+	card card
+	from *column
+	to   *column
+	rule *automateRule
+}
+
+func (r *automateRule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return "when " + r.When + " then " + r.Then
+}
+
+// target returns the column of a "move <column>" action
+func (r *automateRule) target() (string, error) {
+	words := strings.Fields(r.Then)
+	if len(words) < 2 || words[0] != "move" {
+		return "", newError(errInvalidInput, "invalid action %q in rule %v, use \"move <column>\"", r.Then, r)
+	}
+	return strings.Join(words[1:], " "), nil
+}
+
+// automatePath returns the default rules file
+func automatePath() (string, error) {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, userAutomate), nil
+}
+
+// loadAutomateRules reads and checks a rules file, the default one when path
+// is empty
+func loadAutomateRules(path string) ([]automateRule, error) {
+	if path == "" {
+		var err error
+		path, err = automatePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, newError(errNotFound, "no rules file %v, see 'ghp help automate'", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules: %w", err)
+	}
+	f := automateFile{}
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, newError(errInvalidInput, "invalid rules file %v: %v", path, err)
+	}
+	for n := range f.Rules {
+		if strings.TrimSpace(f.Rules[n].When) == "" {
+			return nil, newError(errInvalidInput, "rule %v has no when filter", &f.Rules[n])
+		}
+		_, err = f.Rules[n].target()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f.Rules, nil
+}
+
+// planAutomation returns the moves of the cards matching a rule, the first
+// matching rule wins. Cards already in the rule column don't move and moves
+// over a wip limit are left out unless force is set
+func planAutomation(p *ProjectProxy, me string, rules []automateRule, force bool) ([]automateMove, []string, error) {
+	targets := make([]*column, len(rules))
+	filters := make([][][]string, len(rules))
+	for n := range rules {
+		name, _ := rules[n].target()
+		col, err := findColumn(p, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rule %v: %w", &rules[n], err)
+		}
+		targets[n] = col
+		when := filterFlags{rules[n].When}
+		filters[n] = when.toFilters(me)
+	}
+	wip := map[*column]int{}
+	for n := range p.columns {
+		wip[&p.columns[n]] = p.columns[n].wip()
+	}
+	moves := []automateMove{}
+	warnings := []string{}
+	for n := range p.columns {
+		col := &p.columns[n]
+		for _, c := range col.cards {
+			for r := range rules {
+				if len(rules[r].From) != 0 && !matchColumn(col.name, rules[r].From) {
+					continue
+				}
+				if !c.match(filters[r]) {
+					continue
+				}
+				to := targets[r]
+				if to == col {
+					break
+				}
+				if cardIssue(c) != nil {
+					if to.limit > 0 && wip[to] >= to.limit {
+						msg := fmt.Sprintf("moving %v puts %v over its wip limit, %v/%v", cardName(c), to.name, wip[to]+1, to.limit)
+						if !force {
+							warnings = append(warnings, msg+", skipped")
+							break
+						}
+						warnings = append(warnings, msg)
+					}
+					wip[to]++
+					wip[col]--
+				}
+				moves = append(moves, automateMove{card: c, from: col, to: to, rule: &rules[r]})
+				break
+			}
+		}
+	}
+	return moves, warnings, nil
+}
+
+// applyAutomation moves the cards to the top of their new column, moved is
+// called after every move so a failure still reports the ones made
+func applyAutomation(client *ghpClient, moves []automateMove, moved func(m automateMove)) error {
+	for _, m := range moves {
+		id, err := cardID(m.card)
+		if err == nil {
+			err = client.moveCard(id, "top", m.to.id)
+		}
+		if err != nil {
+			return fmt.Errorf("error moving %v: %w", cardName(m.card), err)
+		}
+		moved(m)
+	}
+	return nil
+}
+
+// automateOptions are the automate flags
+type automateOptions struct {
+	rules  string
+	dryRun bool
+	force  bool
+}
+
+func doAutomate(env *cmdEnv, opts *automateOptions, args []string) error {
+	if len(args) != 1 || (args[0] != "run" && args[0] != "rules") {
+		return newError(errInvalidInput, "use 'ghp automate run' or 'ghp automate rules'")
+	}
+	rules, err := loadAutomateRules(opts.rules)
+	if err != nil {
+		return err
+	}
+	if args[0] == "rules" {
+		for _, r := range rules {
+			detail := "when " + r.When
+			if len(r.From) != 0 {
+				detail += " in " + strings.Join(r.From, ", ")
+			}
+			fmt.Printf("%v\n  %v then %v\n", singleColorHub.headerColorize(r.String()), detail, r.Then)
+		}
+		return nil
+	}
+	err = checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	state := *env.state
+	p, err := loadProject(state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	moves, warnings, err := planAutomation(p, state.User, rules, opts.force)
+	if err != nil {
+		return err
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", msg)
+	}
+	if len(moves) == 0 {
+		if !env.opts.quiet {
+			fmt.Println("Nothing to move")
+		}
+		return nil
+	}
+	report := func(verb string) func(m automateMove) {
+		return func(m automateMove) {
+			fmt.Printf("%v %v from %v to %v  %v\n", verb, cardName(m.card), m.from.name, m.to.name,
+				singleColorHub.styled(singleColorHub.theme.Muted, m.rule.String()))
+		}
+	}
+	if opts.dryRun {
+		for _, m := range moves {
+			report("Would move")(m)
+		}
+		return nil
+	}
+	return applyAutomation(env.client, moves, report("Moved"))
+}
+
+func init() {
+	opts := &automateOptions{}
+	registerCommand(&ghpCommand{
+		name:    "automate",
+		args:    "run|rules",
+		summary: "Move cards with configurable rules",
+		description: `
+Rules are read from ~/.ghp.automate.json or -rules, a list of "when <filter>
+then <action>" evaluated in order, the first matching rule moves the card:
+
+  {"rules": [
+    {"name": "closed to done", "when": "state:closed", "then": "move Done"},
+    {"when": "type:pr review:approved", "from": ["Review"], "then": "move Ready to merge"},
+    {"when": "label:blocked", "then": "move Blocked"}
+  ]}
+
+"when" takes the same filters as -filter, "from" limits the rule to cards in
+some columns and columns are found by name or unique prefix. Moves over a wip
+limit are skipped unless -force is given. 'ghp automate rules' shows the
+rules and -dry-run the planned moves.`,
+		examples: []string{
+			"ghp automate run -dry-run",
+			"ghp automate run -rules team-rules.json",
+		},
+		complete: []string{"run rules"},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			fs.StringVar(&opts.rules, "rules", "", "Rules file, defaults to ~/"+userAutomate)
+			fs.BoolVar(&opts.dryRun, "dry-run", false, "Show the moves without making them")
+			fs.BoolVar(&opts.force, "force", false, "Move cards even over the wip limit")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			return doAutomate(env, opts, args)
+		},
+	})
+}
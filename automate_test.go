@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// testIssue is an open issue of org/api with the given labels
+func testIssue(number int, labels ...string) *issue {
+	i := &issue{repository: &github.Repository{Name: github.String("api")}}
+	i.ghIssue = &github.Issue{Number: github.Int(number), State: github.String("open")}
+	for _, label := range labels {
+		i.ghIssue.Labels = append(i.ghIssue.Labels, &github.Label{Name: github.String(label)})
+	}
+	return i
+}
+
+// plannedMoves describes moves as "ref from → to (rule)"
+func plannedMoves(moves []automateMove) []string {
+	strs := []string{}
+	for _, m := range moves {
+		strs = append(strs, cardName(m.card)+" "+m.from.name+" → "+m.to.name+" ("+m.rule.String()+")")
+	}
+	return strs
+}
+
+func TestPlanAutomation(t *testing.T) {
+	rules := []automateRule{
+		{Name: "reviewed", When: "label:approved", From: []string{"Review"}, Then: "move Done"},
+		{Name: "fixes", When: "label:bug", Then: "move Review"},
+		{Name: "bugs", When: "label:bug", Then: "move Done"},
+		{Name: "approved", When: "label:approved", Then: "move To do"},
+	}
+	board := func() *ProjectProxy {
+		return &ProjectProxy{columns: []column{
+			{name: "To do", cards: []card{testIssue(1, "bug"), testIssue(2, "approved"), &note{text: "plan"}}},
+			{name: "Review", cards: []card{testIssue(3, "approved"), testIssue(4, "bug")}},
+			{name: "Done"},
+		}}
+	}
+
+	moves, warnings, err := planAutomation(board(), "me", rules, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// #1 takes the first matching rule, #2 is already where its rule puts it
+	// and #4 too, even if a later rule would move them
+	want := []string{"api#1 To do → Review (fixes)", "api#3 Review → Done (reviewed)"}
+	if got := plannedMoves(moves); !reflect.DeepEqual(got, want) {
+		t.Errorf("got moves %q, want %q", got, want)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("got warnings %q", warnings)
+	}
+
+	_, _, err = planAutomation(board(), "me", []automateRule{{When: "label:bug", Then: "move Nowhere"}}, false)
+	if err == nil {
+		t.Errorf("a rule to a missing column planned moves")
+	}
+}
+
+func TestPlanAutomationWIP(t *testing.T) {
+	board := func() *ProjectProxy {
+		return &ProjectProxy{columns: []column{
+			{name: "Review", limit: 1, cards: []card{testIssue(1, "approved")}},
+			{name: "To do", cards: []card{testIssue(2, "bug"), testIssue(3, "bug"), &note{text: "https://github.com/org/api/issues/9"}}},
+			{name: "Done"},
+		}}
+	}
+	tests := []struct {
+		name     string
+		rules    []automateRule
+		force    bool
+		moves    []string
+		warnings []string
+	}{
+		{
+			"over the limit is skipped",
+			[]automateRule{{Name: "bugs", When: "label:bug", Then: "move Review"}},
+			false,
+			[]string{},
+			[]string{
+				"moving api#2 puts Review over its wip limit, 2/1, skipped",
+				"moving api#3 puts Review over its wip limit, 2/1, skipped",
+			},
+		},
+		{
+			"force moves anyway",
+			[]automateRule{{Name: "bugs", When: "label:bug", Then: "move Review"}},
+			true,
+			[]string{"api#2 To do → Review (bugs)", "api#3 To do → Review (bugs)"},
+			[]string{
+				"moving api#2 puts Review over its wip limit, 2/1",
+				"moving api#3 puts Review over its wip limit, 3/1",
+			},
+		},
+		{
+			"a card leaving makes room",
+			[]automateRule{
+				{Name: "done", When: "label:approved", Then: "move Done"},
+				{Name: "bugs", When: "label:bug", Then: "move Review"},
+			},
+			false,
+			[]string{"api#1 Review → Done (done)", "api#2 To do → Review (bugs)"},
+			[]string{"moving api#3 puts Review over its wip limit, 2/1, skipped"},
+		},
+	}
+	for _, test := range tests {
+		moves, warnings, err := planAutomation(board(), "me", test.rules, test.force)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if got := plannedMoves(moves); !reflect.DeepEqual(got, test.moves) {
+			t.Errorf("%v: got moves %q, want %q", test.name, got, test.moves)
+		}
+		if len(warnings) == 0 {
+			warnings = []string{}
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%v: got warnings %q, want %q", test.name, warnings, test.warnings)
+		}
+	}
+}
+
+func TestPlanAutomationExactQualifiers(t *testing.T) {
+	closed := testIssue(3, "bug")
+	closed.ghIssue.State = github.String("closed")
+	p := &ProjectProxy{columns: []column{
+		{name: "To do", cards: []card{
+			testIssue(1, "bug-report"),
+			testIssue(2, "bug"),
+			closed,
+			&note{text: "move state:closed label:bug cards to Done"},
+		}},
+		{name: "Done"},
+	}}
+	rules := []automateRule{{Name: "closed", When: "state:closed", Then: "move Done"}, {Name: "bugs", When: "label:bug", Then: "move Done"}}
+	moves, _, err := planAutomation(p, "me", rules, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"api#2 To do → Done (bugs)", "api#3 To do → Done (closed)"}
+	if got := plannedMoves(moves); !reflect.DeepEqual(got, want) {
+		t.Errorf("got moves %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// the board cache is used while 'ghp serve' keeps touching it
+const (
+	boardCacheHeartbeat = 30 * time.Second
+	boardCacheMaxAge    = 2 * time.Minute
+)
+
+// headers kept with the cached responses, Link has the pagination
+var boardCacheHeaders = []string{"Content-Type", "ETag", "Link"}
+
+// cachedResponse is a successful GET response of the board cache
+type cachedResponse struct {
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// boardCache keeps the api responses of the board on disk. 'ghp serve'
+// records them and keeps them fresh with webhooks, the other commands read
+// them instead of asking github while the server is running
+type boardCache struct {
+	mu        sync.Mutex
+	path      string
+	record    bool
+	modified  time.Time
+	responses map[string]*cachedResponse
+	seen      map[string]bool
+	hits      int
+	misses    int
+}
+
+func boardCachePath() (string, error) {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, userBoardCache), nil
+}
+
+func newBoardCache(path string) *boardCache {
+	return &boardCache{path: path, responses: map[string]*cachedResponse{}, seen: map[string]bool{}}
+}
+
+// refresh reads the file again when the server changed it and forgets it
+// when the server is gone, recording caches live in memory
+func (c *boardCache) refresh() {
+	if c.record || c.path == "" {
+		return
+	}
+	info, err := os.Stat(c.path)
+	if err != nil || time.Since(info.ModTime()) > boardCacheMaxAge {
+		c.responses = map[string]*cachedResponse{}
+		c.modified = time.Time{}
+		return
+	}
+	if info.ModTime().Equal(c.modified) {
+		return
+	}
+	responses := map[string]*cachedResponse{}
+	data, err := ioutil.ReadFile(c.path)
+	if err == nil {
+		err = json.Unmarshal(data, &responses)
+	}
+	if err != nil {
+		debugf("ignoring board cache: %v", err)
+		return
+	}
+	c.responses = responses
+	c.modified = info.ModTime()
+}
+
+func (c *boardCache) lookup(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+	r := c.responses[key]
+	if r == nil {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	c.seen[key] = true
+	return r
+}
+
+// stats returns the requests answered from the cache and the ones that
+// weren't
+func (c *boardCache) stats() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// has is true when url or anything under it is cached
+func (c *boardCache) has(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.responses {
+		if underURL(key, url) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *boardCache) store(key string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.record {
+		return
+	}
+	kept := http.Header{}
+	for _, name := range boardCacheHeaders {
+		if value := header.Get(name); value != "" {
+			kept.Set(name, value)
+		}
+	}
+	c.responses[key] = &cachedResponse{Header: kept, Body: string(body)}
+	c.seen[key] = true
+}
+
+// underURL is true for url itself, its subresources and its queries
+func underURL(key, url string) bool {
+	return key == url || strings.HasPrefix(key, url+"/") || strings.HasPrefix(key, url+"?")
+}
+
+// invalidate drops the responses of url and the ones under it
+func (c *boardCache) invalidate(url string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dropped := 0
+	for key := range c.responses {
+		if underURL(key, url) {
+			delete(c.responses, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+func (c *boardCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = map[string]*cachedResponse{}
+}
+
+// startLoad begins tracking the responses used, prune drops the others
+func (c *boardCache) startLoad() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen = map[string]bool{}
+}
+
+func (c *boardCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.responses {
+		if !c.seen[key] {
+			delete(c.responses, key)
+		}
+	}
+}
+
+// save writes the cache, readers never see a partial file
+func (c *boardCache) save() error {
+	c.mu.Lock()
+	data, err := json.Marshal(c.responses)
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error saving board cache: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving board cache: %w", err)
+	}
+	return nil
+}
+
+// touch tells readers the server is still running
+func (c *boardCache) touch() error {
+	now := time.Now()
+	return os.Chtimes(c.path, now, now)
+}
+
+// boardCacheTransport answers GET requests from the board cache, recording
+// caches also store the responses fetched
+type boardCacheTransport struct {
+	base  http.RoundTripper
+	cache *boardCache
+}
+
+func newBoardCacheTransport(base http.RoundTripper, cache *boardCache) *boardCacheTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &boardCacheTransport{base: base, cache: cache}
+}
+
+func (t *boardCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	if cached := t.cache.lookup(key); cached != nil {
+		verbosef("%v %v from the board cache", req.Method, redactURL(req.URL))
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        cached.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !t.cache.record {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	t.cache.store(key, resp.Header, body)
+	return resp, nil
+}
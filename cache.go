@@ -1,8 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/mitchellh/go-homedir"
 	"github.com/patrickmn/go-cache"
 )
 
@@ -10,22 +16,87 @@ type appCache struct {
 	cacheHits int
 	cacheMiss int
 	cache     *cache.Cache
+	index     boardIndex
 }
 
-//TODO: retrieve disk cache if exists
+// boardIndex is the part of the cache kept on disk between runs, column
+// names and card refs of the last listed project, used by completions
+type boardIndex struct {
+	ProjectID int64     `json:"project_id"`
+	Columns   []string  `json:"columns"`
+	Cards     []string  `json:"cards"`
+	Updated   time.Time `json:"updated"`
+}
+
+// initCache returns an empty api cache with the board index read from disk
 func initCache() *appCache {
 	var newCache appCache
 	newCache.cache = cache.New(10*time.Minute, 15*time.Minute)
+	err := newCache.load()
+	if err != nil {
+		debugf("no board index: %v", err)
+	}
 	return &newCache
 }
 
-// TODO: unimplemented
-//lint:ignore U1000 uninpremented
-func (*appCache) save() error {
-	// save cache to disk, at predefined path
+func cachePath() (string, error) {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, userCache), nil
+}
+
+func (c *appCache) load() error {
+	path, err := cachePath()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.index)
+}
+
+// save writes the board index to disk
+func (c *appCache) save() error {
+	path, err := cachePath()
+	if err != nil {
+		return fmt.Errorf("error saving cache: %w", err)
+	}
+	data, err := json.Marshal(c.index)
+	if err != nil {
+		return fmt.Errorf("error saving cache: %w", err)
+	}
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving cache: %w", err)
+	}
 	return nil
 }
 
+// indexProject adds the loaded columns and cards of p to the board index,
+// columns skipped by the current listing are kept from previous runs
+func (c *appCache) indexProject(p *ProjectProxy, projectID int64) {
+	if c.index.ProjectID != projectID {
+		c.index = boardIndex{ProjectID: projectID}
+	}
+	for _, col := range p.columns {
+		if !contains(c.index.Columns, col.name) {
+			c.index.Columns = append(c.index.Columns, col.name)
+		}
+		for _, card := range col.cards {
+			i := cardIssue(card)
+			if i != nil && !contains(c.index.Cards, i.ref()) {
+				c.index.Cards = append(c.index.Cards, i.ref())
+			}
+		}
+	}
+	sort.Strings(c.index.Cards)
+	c.index.Updated = time.Now()
+}
+
 func (c *appCache) get(key string) interface{} {
 	cached, found := c.cache.Get(key)
 	if found {
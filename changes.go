@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kinds of card changes between two loads of a board
+const (
+	changeAdded      = "added"
+	changeRemoved    = "removed"
+	changeMoved      = "moved"
+	changeClosed     = "closed"
+	changeReopened   = "reopened"
+	changeReassigned = "reassigned"
+	changeRelabeled  = "relabeled"
+	changeReordered  = "reordered"
+)
+
+// changeKinds in report order
+var changeKinds = []string{changeAdded, changeRemoved, changeMoved, changeReordered,
+	changeClosed, changeReopened, changeRelabeled, changeReassigned}
+
+// cardState is a card as compared between two loads of a board and stored
+// in snapshots. URL is the project card, ContentURL the issue behind it
+type cardState struct {
+	URL        string   `json:"url"`
+	ContentURL string   `json:"content_url,omitempty"`
+	Type       string   `json:"type"`
+	Ref        string   `json:"ref"`
+	Title      string   `json:"title"`
+	Note       string   `json:"note,omitempty"`
+	Column     string   `json:"column"`
+	Position   int      `json:"position"`
+	State      string   `json:"state,omitempty"`
+	Assignees  []string `json:"assignees,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Milestone  string   `json:"milestone,omitempty"`
+}
+
+// cardChange is a change of a card, from and to are the old and new column
+// or assignees
+type cardChange struct {
+	kind string
+	card cardState
+	from string
+	to   string
+}
+
+func (c cardChange) String() string {
+	switch c.kind {
+	case changeMoved, changeReassigned, changeRelabeled:
+		return fmt.Sprintf("%v %v %v → %v", c.card.Ref, c.kind, c.from, c.to)
+	case changeReordered:
+		return fmt.Sprintf("%v %v in %v", c.card.Ref, c.kind, c.card.Column)
+	case changeAdded:
+		return fmt.Sprintf("%v %v to %v", c.card.Ref, c.kind, c.card.Column)
+	case changeRemoved:
+		return fmt.Sprintf("%v %v from %v", c.card.Ref, c.kind, c.card.Column)
+	}
+	return c.card.Ref + " " + c.kind
+}
+
+// newCardState returns the state of the card at position n of col
+func newCardState(col column, n int, c card) cardState {
+	state := cardState{URL: c.getURL(), Column: col.name, Position: n, Title: cardTitle(c)}
+	switch v := c.(type) {
+	case *note:
+		state.Type = "note"
+		state.Ref = "note " + ellipseStr(state.Title, 24)
+		state.Note = v.text
+	case *pullRequest:
+		state.Type = "pr"
+	default:
+		state.Type = "issue"
+	}
+	if i := cardIssue(c); i != nil {
+		state.ContentURL = i.ghIssue.GetURL()
+		state.Ref = i.ref()
+		state.State = i.ghIssue.GetState()
+		state.Assignees = i.assigneeLogins()
+		sort.Strings(state.Assignees)
+		state.Labels = i.labelNames()
+		sort.Strings(state.Labels)
+		state.Milestone = i.ghIssue.GetMilestone().GetTitle()
+	}
+	return state
+}
+
+// boardStates returns the state of every loaded card in board order
+func boardStates(p *ProjectProxy) []cardState {
+	states := []cardState{}
+	for _, col := range p.columns {
+		for n, c := range col.cards {
+			states = append(states, newCardState(col, n, c))
+		}
+	}
+	return states
+}
+
+// diffBoards returns the changes from old to current, cards are the same
+// when their project card url is
+func diffBoards(old, current []cardState) []cardChange {
+	changes := []cardChange{}
+	before := make(map[string]cardState, len(old))
+	for _, state := range old {
+		before[state.URL] = state
+	}
+	seen := make(map[string]bool, len(current))
+	for _, state := range current {
+		seen[state.URL] = true
+		prev, exists := before[state.URL]
+		if !exists {
+			changes = append(changes, cardChange{kind: changeAdded, card: state})
+			continue
+		}
+		if prev.Column != state.Column {
+			changes = append(changes, cardChange{kind: changeMoved, card: state, from: prev.Column, to: state.Column})
+		}
+		if prev.State != state.State {
+			kind := changeClosed
+			if state.State == "open" {
+				kind = changeReopened
+			}
+			changes = append(changes, cardChange{kind: kind, card: state})
+		}
+		if strings.Join(prev.Labels, ",") != strings.Join(state.Labels, ",") {
+			changes = append(changes, cardChange{kind: changeRelabeled, card: state,
+				from: labelsList(prev.Labels), to: labelsList(state.Labels)})
+		}
+		if strings.Join(prev.Assignees, ",") != strings.Join(state.Assignees, ",") {
+			changes = append(changes, cardChange{kind: changeReassigned, card: state,
+				from: assigneesList(prev.Assignees), to: assigneesList(state.Assignees)})
+		}
+	}
+	for _, state := range old {
+		if !seen[state.URL] {
+			changes = append(changes, cardChange{kind: changeRemoved, card: state})
+		}
+	}
+	for _, state := range reordered(before, current) {
+		changes = append(changes, cardChange{kind: changeReordered, card: state})
+	}
+	return changes
+}
+
+// reordered returns the cards that changed their place among the cards
+// staying in the same column. Cards in the longest run keeping the old order
+// didn't move, so a card taken to the top is the only one reported
+func reordered(before map[string]cardState, current []cardState) []cardState {
+	lanes := make(map[string][]cardState)
+	columns := []string{}
+	for _, state := range current {
+		prev, exists := before[state.URL]
+		if !exists || prev.Column != state.Column {
+			continue
+		}
+		if _, seen := lanes[state.Column]; !seen {
+			columns = append(columns, state.Column)
+		}
+		lanes[state.Column] = append(lanes[state.Column], state)
+	}
+	moved := []cardState{}
+	for _, name := range columns {
+		lane := lanes[name]
+		// longest increasing subsequence of the old positions
+		length := make([]int, len(lane))
+		parent := make([]int, len(lane))
+		best := -1
+		for n := range lane {
+			length[n], parent[n] = 1, -1
+			for m := 0; m < n; m++ {
+				if before[lane[m].URL].Position < before[lane[n].URL].Position && length[m]+1 > length[n] {
+					length[n], parent[n] = length[m]+1, m
+				}
+			}
+			if best == -1 || length[n] > length[best] {
+				best = n
+			}
+		}
+		kept := make(map[int]bool)
+		for n := best; n != -1; n = parent[n] {
+			kept[n] = true
+		}
+		for n, state := range lane {
+			if !kept[n] {
+				moved = append(moved, state)
+			}
+		}
+	}
+	return moved
+}
+
+func labelsList(labels []string) string {
+	if len(labels) == 0 {
+		return "no labels"
+	}
+	return strings.Join(labels, ", ")
+}
+
+func assigneesList(logins []string) string {
+	if len(logins) == 0 {
+		return "unassigned"
+	}
+	return "@" + strings.Join(logins, ", @")
+}
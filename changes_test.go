@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testLane is a column of open cards with the given refs in order
+func testLane(column string, refs ...string) []cardState {
+	states := []cardState{}
+	for n, ref := range refs {
+		states = append(states, cardState{URL: "card/" + ref, Ref: ref, Column: column, Position: n, State: "open"})
+	}
+	return states
+}
+
+func changeStrings(changes []cardChange) []string {
+	strs := []string{}
+	for _, c := range changes {
+		strs = append(strs, c.String())
+	}
+	return strs
+}
+
+func TestDiffBoards(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     []cardState
+		current func() []cardState
+		want    []string
+	}{
+		{
+			"unchanged",
+			testLane("To do", "a", "b"),
+			func() []cardState { return testLane("To do", "a", "b") },
+			[]string{},
+		},
+		{
+			"added",
+			testLane("To do", "a"),
+			func() []cardState { return testLane("To do", "a", "b") },
+			[]string{"b added to To do"},
+		},
+		{
+			"removed",
+			testLane("To do", "a", "b"),
+			func() []cardState { return testLane("To do", "a") },
+			[]string{"b removed from To do"},
+		},
+		{
+			"moved",
+			testLane("To do", "a", "b"),
+			func() []cardState {
+				return append(testLane("To do", "a"), testLane("Done", "b")...)
+			},
+			[]string{"b moved To do → Done"},
+		},
+		{
+			"closed",
+			testLane("To do", "a", "b"),
+			func() []cardState {
+				states := testLane("To do", "a", "b")
+				states[0].State = "closed"
+				return states
+			},
+			[]string{"a closed"},
+		},
+		{
+			"reopened",
+			[]cardState{{URL: "card/a", Ref: "a", Column: "Done", State: "closed"}},
+			func() []cardState { return testLane("Done", "a") },
+			[]string{"a reopened"},
+		},
+		{
+			"reassigned",
+			testLane("To do", "a"),
+			func() []cardState {
+				states := testLane("To do", "a")
+				states[0].Assignees = []string{"ann", "bob"}
+				return states
+			},
+			[]string{"a reassigned unassigned → @ann, @bob"},
+		},
+		{
+			"card taken to the top",
+			testLane("To do", "a", "b", "c", "d"),
+			func() []cardState { return testLane("To do", "d", "a", "b", "c") },
+			[]string{"d reordered in To do"},
+		},
+		{
+			"swapped",
+			testLane("To do", "a", "b", "c"),
+			func() []cardState { return testLane("To do", "a", "c", "b") },
+			[]string{"b reordered in To do"},
+		},
+		{
+			"cards leaving don't reorder the others",
+			testLane("To do", "a", "b", "c"),
+			func() []cardState {
+				return append(testLane("To do", "a", "c"), testLane("Done", "b")...)
+			},
+			[]string{"b moved To do → Done"},
+		},
+	}
+	for _, test := range tests {
+		got := changeStrings(diffBoards(test.old, test.current()))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
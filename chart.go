@@ -0,0 +1,503 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+)
+
+// chart kinds for 'ghp chart'
+var chartKinds = []string{"cfd", "burndown", "throughput"}
+
+// chart styles, stacked series are drawn bottom up
+const (
+	chartStacked = "stacked"
+	chartLine    = "line"
+	chartBars    = "bars"
+)
+
+const (
+	chartHeight      = 16
+	chartLabelWidth  = 6
+	chartFileSamples = 240
+	chartDateFormat  = "Jan 2"
+	defaultChartDays = 30
+)
+
+// chartPalette are the series colors, the same in the terminal and in files
+var chartPalette = []string{"#4e79a7", "#f28e2b", "#59a14f", "#e15759", "#76b7b2", "#edc948", "#b07aa1",
+	"#ff9da7", "#9c755f", "#bab0ac"}
+
+// chartGlyphs tell stacked series apart without color
+var chartGlyphs = []string{"█", "▓", "▒", "░", "#", "%", "=", "+", ":", "."}
+
+// eighthBlocks draw the top of bars with more resolution than a line
+var eighthBlocks = []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// pointsLabel matches story point labels like "points:3", "sp/5" or
+// "8 points"
+var pointsLabel = regexp.MustCompile(`(?i)^(?:(?:story[ _-]?)?(?:points?|pts|sp|estimate)\s*[:=/ ]\s*(\d+(?:\.\d+)?)|(\d+(?:\.\d+)?)\s*(?:points?|pts|sp))$`)
+
+type chartSeries struct {
+	name   string
+	values []float64 // NaN for samples in the future
+}
+
+// chart is a set of series sampled at times. Ideal is the expected line of a
+// burndown, NaN before there is work
+type chart struct {
+	title  string
+	style  string
+	times  []time.Time
+	series []chartSeries
+	ideal  []float64
+}
+
+// maxValue is the highest point of the chart
+func (c *chart) maxValue() float64 {
+	top := 0.0
+	for n := range c.times {
+		total := 0.0
+		for _, s := range c.series {
+			if math.IsNaN(s.values[n]) {
+				continue
+			}
+			if c.style == chartStacked {
+				total += s.values[n]
+			} else {
+				total = math.Max(total, s.values[n])
+			}
+		}
+		if c.ideal != nil && !math.IsNaN(c.ideal[n]) {
+			total = math.Max(total, c.ideal[n])
+		}
+		top = math.Max(top, total)
+	}
+	return top
+}
+
+// legendValue is the last value of a series, or the total for bars
+func (c *chart) legendValue(s chartSeries) float64 {
+	value := 0.0
+	for _, v := range s.values {
+		switch {
+		case math.IsNaN(v):
+		case c.style == chartBars:
+			value += v
+		default:
+			value = v
+		}
+	}
+	return value
+}
+
+// niceCeil rounds the top of the axis up to 1, 2 or 5 times a power of 10
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5} {
+		if v <= step*exp {
+			return step * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+// cardPoints returns the story points of the first points label of a card
+func cardPoints(i *issue) (float64, bool) {
+	for _, label := range i.ghIssue.Labels {
+		match := pointsLabel.FindStringSubmatch(strings.TrimSpace(label.GetName()))
+		if match == nil {
+			continue
+		}
+		points, err := strconv.ParseFloat(match[1]+match[2], 64)
+		if err == nil {
+			return points, true
+		}
+	}
+	return 0, false
+}
+
+// columnAt returns the column of a card at t, empty when it wasn't in the
+// project
+func columnAt(visits []columnVisit, t time.Time) string {
+	column := ""
+	for _, visit := range visits {
+		if visit.since.After(t) {
+			break
+		}
+		column = visit.column
+	}
+	return column
+}
+
+// sampleTimes splits since to until in n evenly spaced times
+func sampleTimes(since, until time.Time, n int) []time.Time {
+	n = max(n, 2)
+	times := make([]time.Time, n)
+	step := until.Sub(since) / time.Duration(n-1)
+	for k := range times {
+		times[k] = since.Add(step * time.Duration(k))
+	}
+	return times
+}
+
+// cfdChart counts the cards in every column at each time, the done column
+// goes at the bottom
+func cfdChart(histories []cardHistory, stages flowStages, times []time.Time, now time.Time) *chart {
+	c := &chart{style: chartStacked, times: times}
+	for n := len(stages.order) - 1; n >= 0; n-- {
+		s := chartSeries{name: stages.order[n], values: make([]float64, len(times))}
+		for k, t := range times {
+			if t.After(now) {
+				s.values[k] = math.NaN()
+				continue
+			}
+			for _, h := range histories {
+				if columnAt(h.visits, t) == s.name {
+					s.values[k]++
+				}
+			}
+		}
+		c.series = append(c.series, s)
+	}
+	return c
+}
+
+// burndownChart sums the cards, or points, in the project and not done at
+// each time. The ideal line goes from the first time with work left to zero
+// at the end
+func burndownChart(histories []cardHistory, stages flowStages, times []time.Time, points bool, now time.Time) *chart {
+	c := &chart{style: chartLine, times: times}
+	s := chartSeries{name: "remaining", values: make([]float64, len(times))}
+	for k, t := range times {
+		if t.After(now) {
+			s.values[k] = math.NaN()
+			continue
+		}
+		for _, h := range histories {
+			column := columnAt(h.visits, t)
+			closed := !h.closed.IsZero() && !h.closed.After(t)
+			if column == "" || column == stages.done || closed {
+				continue
+			}
+			weight := 1.0
+			if points {
+				weight, _ = cardPoints(h.issue)
+			}
+			s.values[k] += weight
+		}
+	}
+	c.series = []chartSeries{s}
+	first := 0
+	for first < len(times)-1 && !(s.values[first] > 0) {
+		first++
+	}
+	// work showing up at the last time leaves no room for the ideal line
+	c.ideal = make([]float64, len(times))
+	for k := range times {
+		c.ideal[k] = math.NaN()
+		if k >= first && first < len(times)-1 && s.values[first] > 0 {
+			c.ideal[k] = s.values[first] * float64(len(times)-1-k) / float64(len(times)-1-first)
+		}
+	}
+	return c
+}
+
+// throughputChart counts the cards done per day, or per week for more than
+// a month
+func throughputChart(flows []*cardFlow, since, until, now time.Time) *chart {
+	c := &chart{style: chartBars}
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	days := 1
+	if until.Sub(since) > 31*24*time.Hour {
+		start, days = weekStart(since), 7
+	}
+	for t := start; t.Before(until); t = t.AddDate(0, 0, days) {
+		c.times = append(c.times, t)
+	}
+	s := chartSeries{name: "done", values: make([]float64, len(c.times))}
+	for k, t := range c.times {
+		if t.After(now) {
+			s.values[k] = math.NaN()
+			continue
+		}
+		end := t.AddDate(0, 0, days)
+		for _, f := range flows {
+			if f.Done != nil && !f.Done.Before(t) && f.Done.Before(end) {
+				s.values[k]++
+			}
+		}
+	}
+	c.series = []chartSeries{s}
+	return c
+}
+
+// chartGlyph is the cell of a series, colored or with its own character
+func chartGlyph(n int, block string) string {
+	if !color.Enable {
+		if block == "█" {
+			block = chartGlyphs[n%len(chartGlyphs)]
+		}
+		return block
+	}
+	return singleColorHub.styled(chartPalette[n%len(chartPalette)], block)
+}
+
+// chartCell draws the cell of sample k between bottom and top values
+func chartCell(c *chart, k int, bottom, top float64) string {
+	if c.style == chartStacked {
+		middle := (bottom + top) / 2
+		total := 0.0
+		for n, s := range c.series {
+			if math.IsNaN(s.values[k]) {
+				return " "
+			}
+			total += s.values[k]
+			if middle < total {
+				return chartGlyph(n, "█")
+			}
+		}
+		return " "
+	}
+	if c.ideal != nil && c.ideal[k] >= bottom && c.ideal[k] < top {
+		return singleColorHub.styled(singleColorHub.theme.Muted, "•")
+	}
+	v := c.series[0].values[k]
+	if math.IsNaN(v) || v <= bottom {
+		return " "
+	}
+	eighths := 8
+	if v < top {
+		eighths = int((v - bottom) / (top - bottom) * 8)
+	}
+	return chartGlyph(0, eighthBlocks[eighths])
+}
+
+// writeChartText draws the chart width characters wide
+func writeChartText(w io.Writer, c *chart, width int) {
+	fmt.Fprintln(w, singleColorHub.headerColorize(c.title))
+	fmt.Fprintln(w)
+	cols := width - chartLabelWidth - 2
+	samples := len(c.times)
+	top := niceCeil(c.maxValue())
+	for row := 0; row < chartHeight; row++ {
+		bottomValue := top * float64(chartHeight-1-row) / chartHeight
+		topValue := top * float64(chartHeight-row) / chartHeight
+		axis := strings.Repeat(" ", chartLabelWidth) + " │"
+		switch row {
+		case 0:
+			axis = fmt.Sprintf("%*v ┤", chartLabelWidth, formatNumber(topValue))
+		case chartHeight / 2:
+			axis = fmt.Sprintf("%*v ┤", chartLabelWidth, formatNumber(topValue))
+		}
+		line := axis
+		for x := 0; x < cols; x++ {
+			k := x * samples / cols
+			if c.style == chartBars && cols >= 2*samples && (x+1)*samples/cols != k {
+				line += " "
+				continue
+			}
+			line += chartCell(c, k, bottomValue, topValue)
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%*v └%v\n", chartLabelWidth, 0, strings.Repeat("─", cols))
+
+	dates := []rune(strings.Repeat(" ", chartLabelWidth+2+cols))
+	place := func(x int, t time.Time, align int) {
+		label := []rune(t.Format(chartDateFormat))
+		x = x - align*len(label)/2
+		if x < 0 || x+len(label) > len(dates) {
+			return
+		}
+		copy(dates[chartLabelWidth+2+x:], label)
+	}
+	place(0, c.times[0], 0)
+	place(cols/2, c.times[samples/2], 1)
+	place(cols, c.times[samples-1], 2)
+	fmt.Fprintln(w, strings.TrimRight(string(dates), " "))
+	fmt.Fprintln(w)
+
+	legend := []string{}
+	for n := len(c.series) - 1; n >= 0; n-- {
+		s := c.series[n]
+		legend = append(legend, fmt.Sprintf("%v %v %v", chartGlyph(n, "█"), s.name, formatNumber(c.legendValue(s))))
+	}
+	if c.ideal != nil {
+		legend = append(legend, singleColorHub.styled(singleColorHub.theme.Muted, "•")+" ideal")
+	}
+	fmt.Fprintln(w, strings.Join(legend, "   "))
+}
+
+// chartOptions are the chart flags
+type chartOptions struct {
+	since  string
+	until  string
+	start  string
+	done   string
+	points bool
+	output string
+}
+
+// chartDates parses the since and until flags, until defaults to now and
+// since to a month before
+func chartDates(opts *chartOptions, now time.Time) (time.Time, time.Time, error) {
+	parse := func(name, value string, fallback time.Time) (time.Time, error) {
+		if value == "" {
+			return fallback, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return t, newError(errInvalidInput, "invalid %v date %v, use yyyy-mm-dd", name, value)
+		}
+		return t, nil
+	}
+	until, err := parse("until", opts.until, now)
+	if err != nil {
+		return until, until, err
+	}
+	if opts.until != "" {
+		until = until.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	since, err := parse("since", opts.since, until.AddDate(0, 0, -defaultChartDays))
+	if err != nil {
+		return since, until, err
+	}
+	if !since.Before(until) {
+		return since, until, newError(errInvalidInput, "since must be before until")
+	}
+	return since, until, nil
+}
+
+func doChart(env *cmdEnv, opts *chartOptions, args []string) error {
+	if len(args) != 1 || !contains(chartKinds, args[0]) {
+		return newError(errInvalidInput, "use 'ghp chart %v'", strings.Join(chartKinds, "|"))
+	}
+	kind := args[0]
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(opts.output)), ".")
+	if opts.output != "" && format != "svg" && format != "png" {
+		return newError(errInvalidInput, "charts are written as .svg or .png files, not %v", opts.output)
+	}
+	now := time.Now()
+	since, until, err := chartDates(opts, now)
+	if err != nil {
+		return err
+	}
+	stages, histories, err := loadHistories(env, opts.start, opts.done)
+	if err != nil {
+		return err
+	}
+	width := consoleWidth()
+	samples := width - chartLabelWidth - 2
+	if opts.output != "" {
+		samples = chartFileSamples
+	}
+	times := sampleTimes(since, until, samples)
+
+	project := env.state.DefaultProject
+	var c *chart
+	switch kind {
+	case "cfd":
+		c = cfdChart(histories, stages, times, now)
+		c.title = "Cumulative flow of " + project
+	case "burndown":
+		c = burndownChart(histories, stages, times, opts.points, now)
+		c.title = "Burndown of " + project + ", cards left"
+		if opts.points {
+			c.title = "Burndown of " + project + ", points left"
+		}
+		missing := 0
+		for _, h := range histories {
+			if _, estimated := cardPoints(h.issue); !estimated {
+				missing++
+			}
+		}
+		if opts.points && missing != 0 && !env.opts.quiet {
+			fmt.Fprintf(os.Stderr, "%v cards without a points label count as 0\n", missing)
+		}
+	case "throughput":
+		flows := []*cardFlow{}
+		for _, h := range histories {
+			flows = append(flows, newCardFlow(h.issue, h.column, h.visits, h.closed, stages, now))
+		}
+		c = throughputChart(flows, since, until, now)
+		c.title = "Throughput of " + project + ", cards done per day"
+		if len(c.times) > 1 && c.times[1].Sub(c.times[0]) > 24*time.Hour {
+			c.title = "Throughput of " + project + ", cards done per week"
+		}
+	}
+
+	if opts.output == "" {
+		writeChartText(os.Stdout, c, width)
+		return nil
+	}
+	file, err := os.Create(opts.output)
+	if err != nil {
+		return fmt.Errorf("error writing chart: %w", err)
+	}
+	defer file.Close()
+	if format == "png" {
+		err = writeChartPNG(file, c)
+	} else {
+		err = writeChartSVG(file, c)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing chart: %w", err)
+	}
+	if !env.opts.quiet {
+		fmt.Fprintf(os.Stderr, "Chart written to %v\n", opts.output)
+	}
+	return nil
+}
+
+func init() {
+	opts := &chartOptions{}
+	registerCommand(&ghpCommand{
+		name:    "chart",
+		args:    strings.Join(chartKinds, "|"),
+		summary: "Draw cumulative flow, burndown or throughput charts",
+		description: `
+Charts use the column history of the cards, like 'ghp stats'. cfd stacks the
+cards in every column over time, burndown shows the cards not done yet, or
+their story points with -points, and throughput the cards done per day, or per
+week for more than a month.
+
+Charts are drawn to the terminal width, -output writes an svg or png file
+instead. Story points come from labels like "points:3", "sp/5" or "8 points",
+cards without one count as 0.`,
+		examples: []string{
+			"ghp chart cfd -since 2026-09-01",
+			"ghp chart burndown -points -since 2026-10-05 -until 2026-10-16",
+			"ghp chart throughput -since 2026-06-01 -output throughput.svg",
+		},
+		complete: []string{strings.Join(chartKinds, " ")},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			fs.StringVar(&opts.since, "since", "", "First day of the chart, as yyyy-mm-dd, defaults to a month ago")
+			fs.StringVar(&opts.until, "until", "", "Last day of the chart, as yyyy-mm-dd, defaults to today")
+			fs.StringVar(&opts.start, "start", "", "Column starting the cycle, by name or prefix")
+			fs.StringVar(&opts.done, "done", "", "Column ending the cycle, by name or prefix")
+			fs.BoolVar(&opts.points, "points", false, "Weight the burndown by story point labels")
+			fs.StringVar(&opts.output, "output", "", "Write the chart to an .svg or .png file")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			return doChart(env, opts, args)
+		},
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func chartDay(d int) time.Time {
+	return time.Date(2020, 3, d, 12, 0, 0, 0, time.UTC)
+}
+
+// chartValues writes values as "1 2 -", with - for NaN
+func chartValues(values []float64) string {
+	strs := []string{}
+	for _, v := range values {
+		if math.IsNaN(v) {
+			strs = append(strs, "-")
+			continue
+		}
+		strs = append(strs, formatNumber(v))
+	}
+	return strings.Join(strs, " ")
+}
+
+func chartSeriesValues(c *chart) []string {
+	strs := []string{}
+	for _, s := range c.series {
+		strs = append(strs, s.name+": "+chartValues(s.values))
+	}
+	return strs
+}
+
+func TestColumnAt(t *testing.T) {
+	visits := []columnVisit{{"To do", chartDay(1)}, {"Review", chartDay(3)}, {"", chartDay(5)}}
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{chartDay(1).Add(-time.Hour), ""},
+		{chartDay(1), "To do"},
+		{chartDay(4), "Review"},
+		{chartDay(6), ""},
+	}
+	for _, test := range tests {
+		if got := columnAt(visits, test.at); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.at, got, test.want)
+		}
+	}
+	if got := columnAt(nil, chartDay(1)); got != "" {
+		t.Errorf("no visits: got %q", got)
+	}
+}
+
+func TestCardPoints(t *testing.T) {
+	tests := []struct {
+		labels []string
+		points float64
+		found  bool
+	}{
+		{[]string{"points:3"}, 3, true},
+		{[]string{"sp/5"}, 5, true},
+		{[]string{"8 points"}, 8, true},
+		{[]string{"Story Points: 2.5"}, 2.5, true},
+		{[]string{"estimate=13"}, 13, true},
+		{[]string{"pts 1"}, 1, true},
+		{[]string{"5sp"}, 5, true},
+		{[]string{"bug", "sp/2", "points:3"}, 2, true},
+		{[]string{"points"}, 0, false},
+		{[]string{"blueprints:3"}, 0, false},
+		{[]string{"8 points later"}, 0, false},
+		{nil, 0, false},
+	}
+	for _, test := range tests {
+		points, found := cardPoints(testIssue(1, test.labels...))
+		if points != test.points || found != test.found {
+			t.Errorf("%q: got %v %v, want %v %v", test.labels, points, found, test.points, test.found)
+		}
+	}
+}
+
+// chartHistories are api#1, moved from To do to Review on day 3 and done on
+// day 5 with 3 points, and api#2, added to To do on day 2 and closed there on
+// day 4 with 5 points
+func chartHistories() []cardHistory {
+	return []cardHistory{
+		{
+			issue:  testIssue(1, "points:3"),
+			visits: []columnVisit{{"To do", chartDay(1)}, {"Review", chartDay(3)}, {"Done", chartDay(5)}},
+		},
+		{
+			issue:  testIssue(2, "points:5"),
+			visits: []columnVisit{{"To do", chartDay(2)}},
+			closed: chartDay(4),
+		},
+	}
+}
+
+func TestCfdChart(t *testing.T) {
+	stages, _ := newFlowStages([]string{"To do", "Review", "Done"}, "", "")
+	times := []time.Time{chartDay(1), chartDay(3), chartDay(5), chartDay(7)}
+	c := cfdChart(chartHistories(), stages, times, chartDay(6))
+	want := []string{"Done: 0 0 1 -", "Review: 0 1 0 -", "To do: 1 1 1 -"}
+	if got := chartSeriesValues(c); !reflect.DeepEqual(got, want) || c.style != chartStacked {
+		t.Errorf("got %v %q, want %q", c.style, got, want)
+	}
+}
+
+func TestBurndownChart(t *testing.T) {
+	stages, _ := newFlowStages([]string{"To do", "Review", "Done"}, "", "")
+	tests := []struct {
+		name      string
+		times     []time.Time
+		points    bool
+		remaining string
+		ideal     string
+	}{
+		{"cards", []time.Time{chartDay(1), chartDay(2), chartDay(4), chartDay(5), chartDay(7)}, false,
+			"1 2 1 0 -", "1 0.8 0.5 0.3 0"},
+		{"points", []time.Time{chartDay(1), chartDay(2), chartDay(4), chartDay(5), chartDay(7)}, true,
+			"3 8 3 0 -", "3 2.3 1.5 0.8 0"},
+		{"work from the second time", []time.Time{chartDay(1).Add(-time.Hour), chartDay(1), chartDay(5)}, false,
+			"0 1 0", "- 1 0"},
+		{"work only at the last time", []time.Time{chartDay(1).Add(-2 * time.Hour), chartDay(1).Add(-time.Hour), chartDay(1)}, false,
+			"0 0 1", "- - -"},
+		{"no work", []time.Time{chartDay(1).Add(-2 * time.Hour), chartDay(1).Add(-time.Hour)}, false, "0 0", "- -"},
+	}
+	for _, test := range tests {
+		c := burndownChart(chartHistories(), stages, test.times, test.points, chartDay(6))
+		remaining, ideal := chartValues(c.series[0].values), chartValues(c.ideal)
+		if remaining != test.remaining || ideal != test.ideal {
+			t.Errorf("%v: got remaining %v and ideal %v, want %v and %v", test.name, remaining, ideal, test.remaining, test.ideal)
+		}
+	}
+}
+
+func TestThroughputChart(t *testing.T) {
+	done := func(days ...int) []*cardFlow {
+		flows := []*cardFlow{{}}
+		for _, d := range days {
+			at := chartDay(d)
+			flows = append(flows, &cardFlow{Done: &at})
+		}
+		return flows
+	}
+	tests := []struct {
+		name         string
+		flows        []*cardFlow
+		since, until time.Time
+		first        string
+		values       string
+	}{
+		{"days", done(1, 2, 2, 4), chartDay(1), chartDay(4), "2020-03-01", "1 2 0 1"},
+		{"future days", done(1), chartDay(1), chartDay(4), "2020-03-01", "1 0 0 -"},
+		// weeks start on monday, 2020-03-01 is a sunday
+		{"weeks", done(1, 2, 9, 30), time.Date(2020, 2, 5, 0, 0, 0, 0, time.UTC), chartDay(10), "2020-02-03",
+			"0 0 0 1 1 1"},
+	}
+	for _, test := range tests {
+		now := chartDay(30)
+		if strings.HasPrefix(test.name, "future") {
+			now = chartDay(3)
+		}
+		c := throughputChart(test.flows, test.since, test.until, now)
+		first, values := c.times[0].Format("2006-01-02"), chartValues(c.series[0].values)
+		if first != test.first || values != test.values {
+			t.Errorf("%v: got %v %v, want %v %v", test.name, first, values, test.first, test.values)
+		}
+	}
+}
+
+func TestChartDates(t *testing.T) {
+	now := time.Date(2020, 3, 31, 15, 0, 0, 0, time.Local)
+	day := func(d, hour, min, sec int) time.Time { return time.Date(2020, 3, d, hour, min, sec, 0, time.Local) }
+	tests := []struct {
+		since, until string
+		from, to     time.Time
+		err          bool
+	}{
+		{"", "", now.AddDate(0, 0, -defaultChartDays), now, false},
+		{"2020-03-10", "", day(10, 0, 0, 0), now, false},
+		{"", "2020-03-20", day(20, 23, 59, 59).AddDate(0, 0, -defaultChartDays), day(20, 23, 59, 59), false},
+		{"2020-03-10", "2020-03-10", day(10, 0, 0, 0), day(10, 23, 59, 59), false},
+		{"2020-03-11", "2020-03-10", time.Time{}, time.Time{}, true},
+		{"10/03/2020", "", time.Time{}, time.Time{}, true},
+		{"", "tomorrow", time.Time{}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		from, to, err := chartDates(&chartOptions{since: test.since, until: test.until}, now)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q %q: got %v %v, want an error", test.since, test.until, from, to)
+			}
+			continue
+		}
+		if err != nil || !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("%q %q: got %v %v %v, want %v %v", test.since, test.until, from, to, err, test.from, test.to)
+		}
+	}
+}
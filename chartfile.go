@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// chart file geometry, the legend goes on the right of the plot
+const (
+	chartFileWidth  = 960
+	chartFileHeight = 480
+	chartFileLeft   = 64
+	chartFileRight  = 200
+	chartFileTop    = 56
+	chartFileBottom = 48
+	chartTicks      = 5
+)
+
+var (
+	chartBackground = [3]uint8{0xff, 0xff, 0xff}
+	chartGrid       = [3]uint8{0xe1, 0xe4, 0xe8}
+	chartAxis       = [3]uint8{0x58, 0x60, 0x69}
+	chartText       = [3]uint8{0x24, 0x29, 0x2e}
+)
+
+// chartLayout places the samples of a chart in a file
+type chartLayout struct {
+	c      *chart
+	top    float64
+	valid  int // samples before the future ones
+	left   float64
+	plotY  float64
+	width  float64
+	height float64
+}
+
+func newChartLayout(c *chart) chartLayout {
+	l := chartLayout{c: c, top: niceCeil(c.maxValue()), valid: len(c.times), left: chartFileLeft, plotY: chartFileTop,
+		width: chartFileWidth - chartFileLeft - chartFileRight, height: chartFileHeight - chartFileTop - chartFileBottom}
+	for k := range c.times {
+		if math.IsNaN(c.series[0].values[k]) {
+			l.valid = k
+			break
+		}
+	}
+	return l
+}
+
+// x is the position of sample k, bars take a slot each
+func (l chartLayout) x(k float64) float64 {
+	if l.c.style == chartBars {
+		return l.left + k*l.width/float64(len(l.c.times))
+	}
+	return l.left + k*l.width/float64(len(l.c.times)-1)
+}
+
+func (l chartLayout) y(v float64) float64 {
+	return l.plotY + l.height - v/l.top*l.height
+}
+
+// value is series n at sample k, stacked series include the ones below.
+// Samples between two others are interpolated
+func (l chartLayout) value(n int, k float64) float64 {
+	at := func(values []float64) float64 {
+		first := int(k)
+		if first+1 >= l.valid {
+			return values[first]
+		}
+		ratio := k - float64(first)
+		return values[first]*(1-ratio) + values[first+1]*ratio
+	}
+	if n < 0 {
+		return 0
+	}
+	if l.c.style != chartStacked {
+		return at(l.c.series[n].values)
+	}
+	total := 0.0
+	for _, s := range l.c.series[:n+1] {
+		total += at(s.values)
+	}
+	return total
+}
+
+// chartDate is a label of the time axis, anchored at its start, middle or end
+type chartDate struct {
+	x      float64
+	label  string
+	anchor string
+}
+
+// dateLabels are the first, middle and last sample times
+func (l chartLayout) dateLabels() []chartDate {
+	last := len(l.c.times) - 1
+	return []chartDate{
+		{l.left, l.c.times[0].Format(chartDateFormat), "start"},
+		{l.left + l.width/2, l.c.times[last/2].Format(chartDateFormat), "middle"},
+		{l.left + l.width, l.c.times[last].Format(chartDateFormat), "end"},
+	}
+}
+
+func hexColor(rgb [3]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// seriesColor is the palette color of series n
+func seriesColor(n int) [3]uint8 {
+	rgb, _ := parseHexColor(strings.TrimPrefix(chartPalette[n%len(chartPalette)], "#"))
+	return rgb
+}
+
+func writeChartSVG(w io.Writer, c *chart) error {
+	l := newChartLayout(c)
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" font-family="sans-serif" font-size="12">`+"\n",
+		chartFileWidth, chartFileHeight, chartFileWidth, chartFileHeight)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="%v"/>`+"\n", hexColor(chartBackground))
+	fmt.Fprintf(b, `<text x="%v" y="32" font-size="18" font-weight="bold" fill="%v">%v</text>`+"\n",
+		chartFileLeft, hexColor(chartText), html.EscapeString(c.title))
+	for tick := 0; tick <= chartTicks; tick++ {
+		v := l.top * float64(tick) / chartTicks
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%v"/>`+"\n",
+			l.left, l.y(v), l.left+l.width, l.y(v), hexColor(chartGrid))
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle" fill="%v">%v</text>`+"\n",
+			l.left-8, l.y(v), hexColor(chartAxis), formatNumber(v))
+	}
+
+	points := func(k int, v float64) string {
+		return fmt.Sprintf("%.1f,%.1f", l.x(float64(k)), l.y(v))
+	}
+	switch c.style {
+	case chartStacked:
+		for n := range c.series {
+			edge := []string{}
+			for k := 0; k < l.valid; k++ {
+				edge = append(edge, points(k, l.value(n, float64(k))))
+			}
+			for k := l.valid - 1; k >= 0; k-- {
+				edge = append(edge, points(k, l.value(n-1, float64(k))))
+			}
+			fmt.Fprintf(b, `<polygon points="%v" fill="%v"/>`+"\n", strings.Join(edge, " "), hexColor(seriesColor(n)))
+		}
+	case chartLine:
+		line := []string{}
+		for k := 0; k < l.valid; k++ {
+			line = append(line, points(k, c.series[0].values[k]))
+		}
+		if l.valid > 0 {
+			area := append([]string{points(0, 0)}, line...)
+			area = append(area, points(l.valid-1, 0))
+			fmt.Fprintf(b, `<polygon points="%v" fill="%v" fill-opacity="0.2"/>`+"\n", strings.Join(area, " "), hexColor(seriesColor(0)))
+		}
+		fmt.Fprintf(b, `<polyline points="%v" fill="none" stroke="%v" stroke-width="2"/>`+"\n", strings.Join(line, " "), hexColor(seriesColor(0)))
+	case chartBars:
+		slot := l.width / float64(len(c.times))
+		for k := 0; k < l.valid; k++ {
+			v := c.series[0].values[k]
+			fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%v"/>`+"\n",
+				l.x(float64(k))+1, l.y(v), math.Max(slot-2, 1), l.y(0)-l.y(v), hexColor(seriesColor(0)))
+		}
+	}
+	if c.ideal != nil {
+		ideal := []string{}
+		for k := range c.times {
+			if !math.IsNaN(c.ideal[k]) {
+				ideal = append(ideal, points(k, c.ideal[k]))
+			}
+		}
+		fmt.Fprintf(b, `<polyline points="%v" fill="none" stroke="%v" stroke-dasharray="6 4"/>`+"\n", strings.Join(ideal, " "), hexColor(chartAxis))
+	}
+
+	fmt.Fprintf(b, `<polyline points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="none" stroke="%v"/>`+"\n",
+		l.left, l.plotY, l.left, l.y(0), l.left+l.width, l.y(0), hexColor(chartAxis))
+	for _, date := range l.dateLabels() {
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="%v" fill="%v">%v</text>`+"\n",
+			date.x, l.y(0)+20, date.anchor, hexColor(chartAxis), date.label)
+	}
+	legendY := l.plotY
+	for n := len(c.series) - 1; n >= 0; n-- {
+		s := c.series[n]
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="12" height="12" fill="%v"/>`+"\n", l.left+l.width+24, legendY, hexColor(seriesColor(n)))
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" fill="%v">%v %v</text>`+"\n", l.left+l.width+42, legendY+10,
+			hexColor(chartText), html.EscapeString(s.name), formatNumber(c.legendValue(s)))
+		legendY += 22
+	}
+	if c.ideal != nil {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%v" stroke-dasharray="4 2"/>`+"\n",
+			l.left+l.width+24, legendY+6, l.left+l.width+36, legendY+6, hexColor(chartAxis))
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" fill="%v">ideal</text>`+"\n", l.left+l.width+42, legendY+10, hexColor(chartText))
+	}
+	fmt.Fprintln(b, "</svg>")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pixelFont is a 3x5 font for png charts, a row per byte with the leftmost
+// pixel as 4. Lowercase letters are drawn as uppercase
+var pixelFont = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7}, '3': {7, 1, 3, 1, 7}, '4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7}, '6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 2, 2}, '8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 7},
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6}, 'E': {7, 4, 6, 4, 7},
+	'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5}, 'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2},
+	'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7}, 'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2},
+	'P': {6, 5, 6, 4, 4}, 'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5}, 'Y': {5, 5, 2, 2, 2},
+	'Z': {7, 1, 2, 4, 7}, '-': {0, 0, 7, 0, 0}, ':': {0, 2, 0, 2, 0}, '/': {1, 1, 2, 4, 4}, '.': {0, 0, 0, 0, 2},
+	',': {0, 0, 0, 2, 4}, '(': {1, 2, 2, 2, 1}, ')': {4, 2, 2, 2, 4}, '#': {5, 7, 5, 7, 5}, '+': {0, 2, 7, 2, 0},
+	'_': {0, 0, 0, 0, 7}, ' ': {0, 0, 0, 0, 0}, '?': {7, 1, 2, 0, 2},
+}
+
+func textWidth(str string, scale int) int {
+	return max(len([]rune(str))*4*scale-scale, 0)
+}
+
+// drawText writes str with its top left corner at x, y
+func drawText(img *image.RGBA, x, y int, str string, rgb [3]uint8, scale int) {
+	for _, r := range strings.ToUpper(str) {
+		glyph, exists := pixelFont[r]
+		if !exists {
+			glyph = pixelFont['?']
+		}
+		for row, bits := range glyph {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>uint(col)) != 0 {
+					fillRect(img, x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale, rgb)
+				}
+			}
+		}
+		x += 4 * scale
+	}
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, rgb [3]uint8) {
+	fill := &image.Uniform{color.RGBA{rgb[0], rgb[1], rgb[2], 0xff}}
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), fill, image.Point{}, draw.Src)
+}
+
+// fillSpan fills the pixel column x between two heights in any order
+func fillSpan(img *image.RGBA, x int, y0, y1 float64, rgb [3]uint8) {
+	top, bottom := math.Min(y0, y1), math.Max(y0, y1)
+	fillRect(img, x, int(math.Round(top)), x+1, int(math.Round(bottom))+1, rgb)
+}
+
+func writeChartPNG(w io.Writer, c *chart) error {
+	l := newChartLayout(c)
+	img := image.NewRGBA(image.Rect(0, 0, chartFileWidth, chartFileHeight))
+	fillRect(img, 0, 0, chartFileWidth, chartFileHeight, chartBackground)
+	drawText(img, chartFileLeft, 16, c.title, chartText, 3)
+	for tick := 0; tick <= chartTicks; tick++ {
+		v := l.top * float64(tick) / chartTicks
+		y := int(math.Round(l.y(v)))
+		fillRect(img, int(l.left), y, int(l.left+l.width), y+1, chartGrid)
+		label := formatNumber(v)
+		drawText(img, int(l.left)-8-textWidth(label, 2), y-5, label, chartAxis, 2)
+	}
+
+	// pixel columns are drawn from the sample they fall on
+	sample := func(x int) (float64, bool) {
+		k := (float64(x) - l.left) / l.width * float64(len(c.times)-1)
+		return k, k <= float64(l.valid-1)
+	}
+	switch c.style {
+	case chartStacked:
+		for x := int(l.left); x <= int(l.left+l.width); x++ {
+			k, valid := sample(x)
+			if !valid {
+				break
+			}
+			for n := range c.series {
+				fillSpan(img, x, l.y(l.value(n-1, k)), l.y(l.value(n, k)), seriesColor(n))
+			}
+		}
+	case chartLine:
+		light := mixColor(seriesColor(0), chartBackground, 0.8)
+		for x := int(l.left); x <= int(l.left+l.width); x++ {
+			k, valid := sample(x)
+			if !valid {
+				break
+			}
+			fillSpan(img, x, l.y(0), l.y(l.value(0, k)), [3]uint8{light[0], light[1], light[2]})
+		}
+		for x := int(l.left); x < int(l.left+l.width); x++ {
+			k, valid := sample(x)
+			next, nextValid := sample(x + 1)
+			if !valid || !nextValid {
+				break
+			}
+			y0, y1 := l.y(l.value(0, k)), l.y(l.value(0, next))
+			fillSpan(img, x, math.Min(y0, y1)-1, math.Max(y0, y1)+1, seriesColor(0))
+		}
+	case chartBars:
+		for k := 0; k < l.valid; k++ {
+			v := c.series[0].values[k]
+			x0, x1 := int(l.x(float64(k)))+1, int(l.x(float64(k+1)))-1
+			fillRect(img, x0, int(math.Round(l.y(v))), max(x1, x0+1), int(l.y(0)), seriesColor(0))
+		}
+	}
+	if c.ideal != nil {
+		for x := int(l.left); x <= int(l.left+l.width); x++ {
+			k := (float64(x) - l.left) / l.width * float64(len(c.times)-1)
+			if (x/6)%2 != 0 || int(k)+1 >= len(c.times) {
+				continue
+			}
+			ratio := k - float64(int(k))
+			v := c.ideal[int(k)]*(1-ratio) + c.ideal[int(k)+1]*ratio
+			if math.IsNaN(v) {
+				continue
+			}
+			fillSpan(img, x, l.y(v)-1, l.y(v)+1, chartAxis)
+		}
+	}
+
+	fillRect(img, int(l.left), int(l.plotY), int(l.left)+1, int(l.y(0))+1, chartAxis)
+	fillRect(img, int(l.left), int(l.y(0)), int(l.left+l.width)+1, int(l.y(0))+1, chartAxis)
+	for _, date := range l.dateLabels() {
+		x := int(date.x)
+		switch date.anchor {
+		case "middle":
+			x -= textWidth(date.label, 2) / 2
+		case "end":
+			x -= textWidth(date.label, 2)
+		}
+		drawText(img, x, int(l.y(0))+12, date.label, chartAxis, 2)
+	}
+	legendX, legendY := int(l.left+l.width)+24, int(l.plotY)
+	for n := len(c.series) - 1; n >= 0; n-- {
+		s := c.series[n]
+		fillRect(img, legendX, legendY, legendX+12, legendY+12, seriesColor(n))
+		drawText(img, legendX+18, legendY+1, ellipseStr(s.name, 16)+" "+formatNumber(c.legendValue(s)), chartText, 2)
+		legendY += 22
+	}
+	if c.ideal != nil {
+		fillRect(img, legendX, legendY+5, legendX+12, legendY+7, chartAxis)
+		drawText(img, legendX+18, legendY+1, "ideal", chartText, 2)
+	}
+	return png.Encode(w, img)
+}
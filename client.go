@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-github/v32/github"
@@ -22,35 +22,43 @@ type deviceOauthResponse struct {
 }
 
 type oauthAuthCodeResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	Scope       string `json:"scope"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
 }
 
 type ghpClient struct {
 	oauthToken string
 	deviceCode string
 	apiClient  *github.Client
+	httpClient *http.Client
 	context    *context.Context
+	rateLimit  *rateLimitTransport
+	board      *boardCache
 }
 
-func oauthCreateDeviceRequest() (*deviceOauthResponse, error) {
+func oauthCreateDeviceRequest(ctx context.Context, client *http.Client) (*deviceOauthResponse, error) {
 	body := strings.NewReader(`client_id=0412cc5fb93b10a59e50&scope=repo`)
-	req, err := http.NewRequest("POST", "https://github.com/login/device/code", body)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://github.com/login/device/code", body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err, "error requesting device code")
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp, "error requesting device code")
+	}
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err, "error reading device code")
 	}
 	var responseJSON deviceOauthResponse
 	err = json.Unmarshal(responseBody, &responseJSON)
@@ -61,9 +69,9 @@ func oauthCreateDeviceRequest() (*deviceOauthResponse, error) {
 }
 
 func (c *ghpClient) prepareDeviceForOauth() (string, string, error) {
-	device, err := oauthCreateDeviceRequest()
+	device, err := oauthCreateDeviceRequest(*c.context, c.httpClient)
 	if err != nil {
-		return "", "", fmt.Errorf("error creating device request: %v", err)
+		return "", "", err
 	}
 	c.deviceCode = device.DeviceCode
 	return device.UserCode, device.VerificationURI, nil
@@ -72,40 +80,59 @@ func (c *ghpClient) prepareDeviceForOauth() (string, string, error) {
 func (c *ghpClient) performOauth() error {
 	body := strings.NewReader(fmt.Sprintf("client_id=0412cc5fb93b10a59e50&device_code=%s&grant_type=urn:ietf:params:oauth:grant-type:device_code", c.deviceCode))
 
-	req, err := http.NewRequest("POST", "https://github.com/login/oauth/access_token", body)
+	req, err := http.NewRequestWithContext(*c.context, "POST", "https://github.com/login/oauth/access_token", body)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil
+		return apiError(err, "error requesting access token")
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return statusError(resp, "error requesting access token")
+	}
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return apiError(err, "error reading access token")
 	}
 	var responseAuth oauthAuthCodeResponse
 	err = json.Unmarshal(responseBody, &responseAuth)
 	if err != nil {
 		return err
 	}
+	if responseAuth.Error != "" {
+		return newError(errAuth, "authorization failed: %v", responseAuth.ErrorDescription)
+	}
+	if responseAuth.AccessToken == "" {
+		return newError(errAuth, "authorization failed: no token received")
+	}
 	c.oauthToken = responseAuth.AccessToken
 	return nil
 }
 
-func createClient(authToken string) *ghpClient {
+// createClient returns a client bound to ctx, cancelling it aborts every
+// request in flight
+func createClient(ctx context.Context, authToken string) *ghpClient {
 	c := new(ghpClient)
 	c.oauthToken = authToken
-	ctx := context.Background()
 	c.context = &ctx
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: c.oauthToken},
 	)
-	tc := oauth2.NewClient(*c.context, ts)
+	logging := newLoggingTransport(nil)
+	c.httpClient = &http.Client{Transport: logging}
+	tc := &http.Client{Transport: &oauth2.Transport{Source: ts, Base: newETagTransport(logging)}}
+	c.rateLimit = newRateLimitTransport(tc.Transport)
+	path, err := boardCachePath()
+	if err != nil {
+		debugf("no board cache: %v", err)
+	}
+	c.board = newBoardCache(path)
+	tc.Transport = newBoardCacheTransport(c.rateLimit, c.board)
 	c.apiClient = github.NewClient(tc)
 	return c
 }
@@ -116,62 +143,181 @@ func (c *ghpClient) getToken() string {
 
 func (c *ghpClient) validToken() (bool, error) {
 	if c.oauthToken == "" {
-		log.Print("Empty token")
+		verbosef("Empty token")
 		return false, nil
 	}
-	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+	req, err := http.NewRequestWithContext(*c.context, "GET", "https://api.github.com/user", nil)
 	if err != nil {
-		return false, fmt.Errorf("error creating request: %v", err)
+		return false, fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("Authorization", "token "+c.oauthToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("error Sending request: %v", err)
+		return false, apiError(err, "error Sending request")
 	}
 	defer resp.Body.Close()
 	_, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return false, fmt.Errorf("error reading body: %v", err)
+		return false, apiError(err, "error reading body")
+	}
+	if resp.StatusCode == 401 {
+		return false, nil
 	}
 	if resp.StatusCode != 200 {
-		return false, fmt.Errorf("client error: %v", resp.StatusCode)
+		return false, statusError(resp, "error validating token")
 	}
 	return true, nil
 }
 
+// getAllColumnCards returns every card of a column, following the pages
 func (c *ghpClient) getAllColumnCards(columnId int64) ([]*github.ProjectCard, error) {
-	cards, res, err := c.apiClient.Projects.ListProjectCards(*c.context, columnId, nil)
+	opts := &github.ProjectCardListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	all := []*github.ProjectCard{}
+	for {
+		cards, res, err := c.apiClient.Projects.ListProjectCards(*c.context, columnId, opts)
+		if err != nil {
+			return nil, apiError(err, "error Getting cards for %v", columnId)
+		}
+		if res.StatusCode != 200 {
+			return nil, statusError(res.Response, "error Getting cards for %v", columnId)
+		}
+		all = append(all, cards...)
+		if res.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+// listColumns returns every column of a project, following the pages
+func (c *ghpClient) listColumns(projectID int64) ([]*github.ProjectColumn, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	all := []*github.ProjectColumn{}
+	for {
+		cols, res, err := c.apiClient.Projects.ListProjectColumns(*c.context, projectID, opts)
+		if err != nil {
+			return nil, apiError(err, "error getting columns for %v", projectID)
+		}
+		if res.StatusCode != 200 {
+			return nil, statusError(res.Response, "error getting columns for %v", projectID)
+		}
+		all = append(all, cols...)
+		if res.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+// timelineAccept enables the previews adding the project card and its column
+// to the project events of issue timelines
+const timelineAccept = "application/vnd.github.mockingbird-preview+json, application/vnd.github.starfox-preview+json"
+
+func (c *ghpClient) getAPIObject(url string, v interface{}) error {
+	_, err := c.getAPIPage(url, v)
+	return err
+}
+
+// getAPIPage reads a page of a list into v and returns the url of the next
+// page, empty on the last one
+func (c *ghpClient) getAPIPage(url string, v interface{}) (string, error) {
+	req, err := c.apiClient.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error Getting cards for %v: %v", columnId, err)
+		return "", newError(errInvalidInput, "invalid url %v: %v", url, err)
+	}
+	if strings.HasSuffix(req.URL.Path, "/timeline") {
+		req.Header.Set("Accept", timelineAccept)
+	}
+	res, err := c.apiClient.Do(*c.context, req, v)
+	if err != nil {
+		return "", apiError(err, "error getting %v", url)
 	}
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("error Getting cards for %v: http: %v", columnId, res.Status)
+		return "", statusError(res.Response, "error getting %v", url)
+	}
+	if res.NextPage == 0 {
+		return "", nil
 	}
-	return cards, nil
+	next := *req.URL
+	query := next.Query()
+	query.Set("page", strconv.Itoa(res.NextPage))
+	next.RawQuery = query.Encode()
+	return next.String(), nil
 }
 
-func (c *ghpClient) listColumns(projectID int64) ([]*github.ProjectColumn, error) {
-	cols, res, err := c.apiClient.Projects.ListProjectColumns(*c.context, projectID, nil)
+func (c *ghpClient) getRateLimits() (*github.RateLimits, error) {
+	limits, _, err := c.apiClient.RateLimits(*c.context)
 	if err != nil {
-		return nil, fmt.Errorf("error getting columns for %v: %v", projectID, err)
+		return nil, apiError(err, "error getting rate limits")
 	}
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("error getting columns for %v: http: %v", projectID, res.Status)
+	return limits, nil
+}
+
+// listProjects returns the open projects of an organization
+func (c *ghpClient) listProjects(org string) ([]*github.Project, error) {
+	opts := &github.ProjectListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	all := []*github.Project{}
+	for {
+		projects, res, err := c.apiClient.Organizations.ListProjects(*c.context, org, opts)
+		if err != nil {
+			return nil, apiError(err, "error getting projects for org %v", org)
+		}
+		all = append(all, projects...)
+		if res.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = res.NextPage
 	}
+}
 
-	return cols, nil
+func (c *ghpClient) createColumn(projectID int64, name string) (*github.ProjectColumn, error) {
+	col, _, err := c.apiClient.Projects.CreateProjectColumn(*c.context, projectID, &github.ProjectColumnOptions{Name: name})
+	if err != nil {
+		return nil, apiError(err, "error creating column %v", name)
+	}
+	return col, nil
 }
 
-func (c *ghpClient) getAPIObject(url string, v interface{}) error {
-	req, _ := c.apiClient.NewRequest("GET", url, nil)
-	res, err := c.apiClient.Do(*c.context, req, v)
+func (c *ghpClient) createCard(columnID int64, opts *github.ProjectCardOptions) (*github.ProjectCard, error) {
+	card, _, err := c.apiClient.Projects.CreateProjectCard(*c.context, columnID, opts)
 	if err != nil {
-		return err
+		return nil, apiError(err, "error creating card in column %v", columnID)
 	}
-	if res.StatusCode != 200 {
-		return fmt.Errorf("error getting %v: %v", url, res.Status)
+	return card, nil
+}
+
+// moveCard moves a card to position, "top", "bottom" or "after:<card id>",
+// of a column
+func (c *ghpClient) moveCard(cardID int64, position string, columnID int64) error {
+	_, err := c.apiClient.Projects.MoveProjectCard(*c.context, cardID, &github.ProjectCardMoveOptions{Position: position, ColumnID: columnID})
+	if err != nil {
+		return apiError(err, "error moving card %v", cardID)
+	}
+	return nil
+}
+
+func (c *ghpClient) addLabels(owner, repo string, number int, labels []string) error {
+	_, _, err := c.apiClient.Issues.AddLabelsToIssue(*c.context, owner, repo, number, labels)
+	if err != nil {
+		return apiError(err, "error labeling %v#%v", repo, number)
+	}
+	return nil
+}
+
+func (c *ghpClient) createComment(owner, repo string, number int, body string) error {
+	_, _, err := c.apiClient.Issues.CreateComment(*c.context, owner, repo, number, &github.IssueComment{Body: &body})
+	if err != nil {
+		return apiError(err, "error commenting on %v#%v", repo, number)
+	}
+	return nil
+}
+
+func (c *ghpClient) deleteCard(cardID int64) error {
+	_, err := c.apiClient.Projects.DeleteProjectCard(*c.context, cardID)
+	if err != nil {
+		return apiError(err, "error deleting card %v", cardID)
 	}
 	return nil
 }
@@ -3,15 +3,33 @@ package main
 import (
 	"math"
 	"math/rand"
+	"strconv"
 
 	jump "github.com/lithammer/go-jump-consistent-hash"
 
+	"github.com/google/go-github/v32/github"
 	"github.com/gookit/color"
 )
 
+// color depths supported by the terminal
+const (
+	depth16 = iota
+	depth256
+	depthTrueColor
+)
+
+// label rendering styles
+const (
+	labelBackground = "background"
+	labelForeground = "foreground"
+)
+
 type colorHub struct {
-	colors map[string]color.RGBColor
-	hasher *jump.Hasher
+	colors     map[string]color.RGBColor
+	hasher     *jump.Hasher
+	depth      int
+	labelStyle string
+	theme      theme
 }
 
 // main colorhub, will be global to program
@@ -28,15 +46,59 @@ func (c *colorHub) hashedColor(seed string) color.RGBColor {
 func (c *colorHub) init() {
 	c.hasher = jump.New(math.MaxInt32, jump.NewCRC64())
 	c.colors = make(map[string]color.RGBColor)
+	c.labelStyle = labelBackground
+	c.theme = builtinThemes[defaultTheme]
+	c.depth = depth16
+	if color.IsSupportTrueColor() {
+		c.depth = depthTrueColor
+	} else if color.IsSupport256Color() {
+		c.depth = depth256
+	}
 }
 
-func (c *colorHub) stableColorize(str string) string {
-	col, exists := c.colors[str]
+// code returns the escape code of col degraded to the terminal color depth
+func (c *colorHub) code(col color.RGBColor) string {
+	switch c.depth {
+	case depthTrueColor:
+		return col.String()
+	case depth256:
+		return col.C256().String()
+	}
+	return col.C16().String()
+}
+
+// seedColor returns the hashed color of seed adjusted for the theme
+func (c *colorHub) seedColor(seed string) color.RGBColor {
+	col, exists := c.colors[seed]
 	if !exists {
-		col = c.hashedColor(str)
-		c.colors[str] = col
+		col = adjustHashed(c.hashedColor(seed), c.theme.Hashed)
+		c.colors[seed] = col
 	}
-	return col.Sprintf("%v", str)
+	return col
+}
+
+func (c *colorHub) stableColorize(str string) string {
+	return color.RenderCode(c.code(c.seedColor(str)), str)
+}
+
+func (c *colorHub) headerColorize(str string) string {
+	return c.styled(c.theme.Header, str)
+}
+
+func (c *colorHub) repoColorize(str string) string {
+	return c.styled(c.theme.Repo, str)
+}
+
+func (c *colorHub) assigneeColorize(str string) string {
+	return c.styled(c.theme.Assignee, str)
+}
+
+func (c *colorHub) noteColorize(str string) string {
+	return c.styled(c.theme.Note, str)
+}
+
+func (c *colorHub) closedColorize(str string) string {
+	return c.styled(c.theme.Closed, str)
 }
 
 func (c *colorHub) keyColorize(key, str string) string {
@@ -44,6 +106,52 @@ func (c *colorHub) keyColorize(key, str string) string {
 	if !exists {
 		return str
 	}
-	h := col.Sprintf("foo %v", str)
+	h := color.RenderCode(c.code(col), "foo "+str)
 	return h
 }
+
+// labelColorize renders a label with its github color, as a chip with
+// contrasting text or as colored text depending on labelStyle. Labels without
+// color fall back to the hashed color
+func (c *colorHub) labelColorize(label *github.Label) string {
+	switch c.theme.Labels {
+	case "none":
+		return label.GetName()
+	case "hashed":
+		return c.stableColorize(label.GetName())
+	}
+	rgb, valid := parseHexColor(label.GetColor())
+	if !valid {
+		return c.stableColorize(label.GetName())
+	}
+	if c.labelStyle == labelForeground {
+		return color.RenderCode(c.code(color.RGB(rgb[0], rgb[1], rgb[2])), label.GetName())
+	}
+	bg := color.RGB(rgb[0], rgb[1], rgb[2], true)
+	fg := color.RGB(0, 0, 0)
+	if luminance(rgb) < 0.5 {
+		fg = color.RGB(255, 255, 255)
+	}
+	return color.RenderCode(c.code(fg)+";"+c.code(bg), label.GetName())
+}
+
+// parseHexColor parses github "rrggbb" colors
+func parseHexColor(hex string) ([3]uint8, bool) {
+	rgb := [3]uint8{}
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	for n := 0; n < 3; n++ {
+		v, err := strconv.ParseUint(hex[n*2:n*2+2], 16, 8)
+		if err != nil {
+			return rgb, false
+		}
+		rgb[n] = uint8(v)
+	}
+	return rgb, true
+}
+
+// luminance returns the perceived brightness of a color between 0 and 1
+func luminance(rgb [3]uint8) float64 {
+	return (0.299*float64(rgb[0]) + 0.587*float64(rgb[1]) + 0.114*float64(rgb[2])) / 255
+}
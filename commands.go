@@ -0,0 +1,448 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// globalOptions are the flags accepted by every command
+type globalOptions struct {
+	color   string
+	theme   string
+	timeout time.Duration
+	verbose bool
+	debug   bool
+}
+
+// cmdEnv is what a command runs with, flags fill it before setup creates
+// the client
+type cmdEnv struct {
+	state    *ghpConfig
+	cache    *appCache
+	client   *ghpClient
+	filters  filterFlags
+	opts     *listOptions
+	viewName string
+	watch    watchFlag
+	global   globalOptions
+
+	// projects listed instead of the default one
+	projects    []string
+	allProjects bool
+}
+
+// ghpCommand is a ghp subcommand. args is the usage of its positional
+// arguments and complete has the completion of each of them, words and
+// "@kind" for the dynamic values of "ghp __complete kind"
+type ghpCommand struct {
+	name        string
+	aliases     []string
+	args        string
+	summary     string
+	description string
+	examples    []string
+	complete    []string
+	hidden      bool
+	offline     bool // doesn't need a github client
+	flags       func(fs *flag.FlagSet, env *cmdEnv)
+	run         func(env *cmdEnv, args []string) error
+}
+
+var ghpCommands = map[string]*ghpCommand{}
+
+// registerCommand adds a command, each command registers itself from init
+func registerCommand(cmd *ghpCommand) {
+	ghpCommands[cmd.name] = cmd
+}
+
+func findCommand(name string) *ghpCommand {
+	if cmd, exists := ghpCommands[name]; exists {
+		return cmd
+	}
+	for _, cmd := range ghpCommands {
+		if contains(cmd.aliases, name) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func isCommand(name string) bool {
+	return findCommand(name) != nil
+}
+
+// commandNames returns the visible commands sorted by name
+func commandNames() []string {
+	names := []string{}
+	for name, cmd := range ghpCommands {
+		if !cmd.hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func globalFlags(fs *flag.FlagSet, env *cmdEnv) {
+	fs.StringVar(&env.global.color, "color", colorAuto, "Colorize output: auto, always or never")
+	fs.StringVar(&env.global.theme, "theme", env.state.Theme, "Color theme: dark, light, high-contrast, monochrome or one defined in config")
+	fs.BoolVar(&env.opts.quiet, "quiet", false, "Don't show progress and informational messages")
+	fs.DurationVar(&env.global.timeout, "timeout", 0, "Abort after this time, like 30s or 2m, 0 waits forever")
+	fs.BoolVar(&env.global.verbose, "verbose", false, "Log a summary of every api request on stderr")
+	fs.BoolVar(&env.global.debug, "debug", false, "Like -verbose, also logging http headers with credentials redacted")
+}
+
+// listFlags are the flags selecting and arranging cards, shared by the
+// commands showing or saving listings
+func listFlags(fs *flag.FlagSet, env *cmdEnv) {
+	opts := env.opts
+	fs.Var(&env.filters, "filter", "Issue filtering, use a comma separated for AND filter and several -filter paramenters for OR filter")
+	fs.StringVar(&opts.sortBy, "sort", "", "Sort cards by "+strings.Join(sortKeys, ", ")+", prefix with - to reverse")
+	fs.StringVar(&opts.groupBy, "group-by", "", "Group cards by "+strings.Join(groupKeys, ", "))
+	fs.Var((*columnFlags)(&opts.columns), "column", "Column to show, by name or prefix, can be repeated or comma separated")
+	fs.Var((*columnFlags)(&opts.columns), "columns", "Alias of -column")
+	fs.Var((*columnFlags)(&opts.excludeColumns), "exclude-column", "Column to skip, by name or prefix, can be repeated or comma separated")
+	fs.StringVar(&env.viewName, "v", "", "Saved view to list")
+}
+
+// linkedFlag adds -linked to the commands showing the linked pull requests
+func linkedFlag(fs *flag.FlagSet, env *cmdEnv) {
+	fs.BoolVar(&env.opts.linked, "linked", false, "Show the pull requests linked to issues, one more request per issue")
+}
+
+// newFlagSet returns the flags of cmd, the global ones only when global is
+// set, errors and usage are left to the caller
+func newFlagSet(cmd *ghpCommand, env *cmdEnv, global bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("ghp "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Usage = func() {}
+	if cmd.flags != nil {
+		cmd.flags(fs, env)
+	}
+	if global {
+		globalFlags(fs, env)
+	}
+	return fs
+}
+
+// parseInterspersed parses flags placed before and after the positional
+// arguments, everything after "--" is positional
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// parseCommandLine finds the command of args and parses its flags into env.
+// Without a command, or when the first argument is a flag, the command is
+// list. Saved views work as list commands unless -v names another view
+func parseCommandLine(env *cmdEnv, args []string) (*ghpCommand, []string, error) {
+	name := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+	cmd := findCommand(name)
+	view := ""
+	if cmd == nil {
+		if _, exists := env.state.Views[name]; !exists {
+			return nil, nil, newError(errInvalidInput, "unknown command %v, see 'ghp help'", name)
+		}
+		cmd = findCommand("list")
+		view = name
+	}
+
+	fs := newFlagSet(cmd, env, true)
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return cmd, nil, err
+	}
+	if err != nil {
+		return cmd, nil, newError(errInvalidInput, "%v, see 'ghp help %v'", err, cmd.name)
+	}
+	// building the flags resets the view name
+	given := false
+	fs.Visit(func(f *flag.Flag) {
+		given = given || f.Name == "v"
+	})
+	if view != "" && !given {
+		env.viewName = view
+	}
+	return cmd, positional, nil
+}
+
+// run parses the command line and runs the command
+func run(args []string) error {
+	state, stateErr := stateLoad()
+	env := &cmdEnv{state: state, cache: initCache(), opts: new(listOptions)}
+
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		printHelp(os.Stdout)
+		return nil
+	}
+	cmd, positional, err := parseCommandLine(env, args)
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandHelp(os.Stdout, cmd)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if env.global.verbose {
+		logLevel = logVerbose
+	}
+	if env.global.debug {
+		logLevel = logDebug
+	}
+	if stateErr != nil {
+		verbosef("Empty state: %v", stateErr)
+	}
+
+	err = setupColor(env.global.color)
+	if err != nil {
+		return err
+	}
+	singleColorHub.init()
+	if state.LabelStyle != "" {
+		singleColorHub.labelStyle = state.LabelStyle
+	}
+	singleColorHub.theme, err = resolveTheme(env.global.theme, state.Themes)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.offline {
+		ctx, cancel := commandContext(env.global.timeout)
+		defer cancel()
+		env.client = createClient(ctx, state.AccessToken)
+	}
+	return cmd.run(env, positional)
+}
+
+// prepareList applies the selected view and config to the list options
+func (env *cmdEnv) prepareList() error {
+	if env.viewName != "" {
+		view, exists := env.state.Views[env.viewName]
+		if !exists {
+			return newError(errInvalidInput, "no view named %v, see 'ghp view ls'", env.viewName)
+		}
+		applyView(view, &env.filters, env.opts)
+	}
+	env.opts.labelPriority = env.state.LabelPriority
+	if len(env.opts.labelPriority) == 0 {
+		env.opts.labelPriority = defaultLabelPriority
+	}
+	return env.opts.validate()
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, "ghp shows and manages github projects from the terminal")
+	fmt.Fprintln(w, "\nUsage:\n  ghp [command] [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range commandNames() {
+		cmd := ghpCommands[name]
+		fmt.Fprintf(tw, "  %v\t%v\n", strings.Join(append([]string{name}, cmd.aliases...), ", "), cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nWithout a command ghp lists the default project, saved views also")
+	fmt.Fprintln(w, "work as commands, 'ghp mywork' lists the view mywork.")
+	fmt.Fprintln(w, "\nGlobal flags:")
+	printDefaults(w, newFlagSet(&ghpCommand{}, &cmdEnv{state: new(ghpConfig), opts: new(listOptions)}, true))
+	fmt.Fprintln(w, "\nRun 'ghp help <command>' for the flags and examples of a command.")
+}
+
+func printCommandHelp(w io.Writer, cmd *ghpCommand) {
+	usage := "ghp " + cmd.name + " [flags]"
+	if cmd.args != "" {
+		usage += " " + cmd.args
+	}
+	fmt.Fprintf(w, "%v\n\nUsage:\n  %v\n", cmd.summary, usage)
+	if len(cmd.aliases) != 0 {
+		fmt.Fprintf(w, "\nAliases:\n  %v\n", strings.Join(cmd.aliases, ", "))
+	}
+	if cmd.description != "" {
+		fmt.Fprintf(w, "\n%v\n", strings.TrimSpace(cmd.description))
+	}
+	env := &cmdEnv{state: new(ghpConfig), opts: new(listOptions)}
+	fs := newFlagSet(cmd, env, false)
+	if hasFlags(fs) {
+		fmt.Fprintln(w, "\nFlags:")
+		printDefaults(w, fs)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	printDefaults(w, newFlagSet(&ghpCommand{}, env, true))
+	if len(cmd.examples) != 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range cmd.examples {
+			fmt.Fprintf(w, "  %v\n", example)
+		}
+	}
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+func printDefaults(w io.Writer, fs *flag.FlagSet) {
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(ioutil.Discard)
+}
+
+func init() {
+	registerCommand(&ghpCommand{
+		name:    "list",
+		aliases: []string{"board"},
+		args:    "[interval]",
+		summary: "List the cards of the default project",
+		description: `
+Filters match the card text or qualifiers like repo:, assignee:, label:,
+state:, type:, review:, ci: or draft:, which match whole values. Terms
+separated by commas must all match, repeated -filter flags match any of them.
+@me is the configured user.
+
+With -watch the list is refreshed every interval (30s by default) until
+interrupted. Cards added (+), moved (»), reordered (↕), closed (✕),
+reopened (↺), relabeled (#) or reassigned (@) since the previous refresh are
+marked, and the last changes are shown below the list.
+
+-projects or -all-projects list several projects of the organization, loaded
+at once, each under its name.`,
+		examples: []string{
+			"ghp list -filter assignee:@me",
+			"ghp list -filter 'type:pr review:required' -filter label:urgent",
+			"ghp list -column 'In progress' -group-by assignee -sort -updated",
+			"ghp list -v mywork",
+			"ghp board -watch 1m -exclude-column Done",
+			"ghp list -projects Backend,Frontend -column 'In progress'",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			linkedFlag(fs, env)
+			projectFlags(fs, env, true)
+			fs.Var(&env.watch, "watch", "Refresh the list every interval, 30s unless given as -watch=1m or after the flag")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if env.watch.enabled && len(args) == 1 {
+				err := env.watch.setInterval(args[0])
+				if err != nil {
+					return newError(errInvalidInput, "%v", err)
+				}
+				args = args[1:]
+			}
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help list'", strings.Join(args, " "))
+			}
+			err := env.prepareList()
+			if err != nil {
+				return err
+			}
+			err = checkAllConfig(env.state, env.client)
+			if err != nil {
+				return err
+			}
+			if len(env.projects) != 0 || env.allProjects {
+				if env.watch.enabled {
+					return newError(errInvalidInput, "-watch lists a single project, drop -projects and -all-projects")
+				}
+				if env.allProjects {
+					env.projects = nil
+				}
+				return doListProjects(*env.state, env.cache, env.client, env.filters, env.opts, env.projects)
+			}
+			if env.watch.enabled {
+				return doWatch(*env.state, env.cache, env.client, env.filters, env.opts, env.watch.interval)
+			}
+			return doList(*env.state, env.cache, env.client, env.filters, env.opts)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:     "auth",
+		summary:  "Authorize ghp in github",
+		examples: []string{"ghp auth"},
+		run: func(env *cmdEnv, args []string) error {
+			return doAuth(env.state, env.client)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:     "config",
+		summary:  "Choose the organization and default project",
+		examples: []string{"ghp config"},
+		run: func(env *cmdEnv, args []string) error {
+			return doConfig(env.state, env.client)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:     "rate-limit",
+		summary:  "Show the github api quota",
+		examples: []string{"ghp rate-limit"},
+		run: func(env *cmdEnv, args []string) error {
+			return doRateLimit(env.client)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:    "view",
+		args:    "save|ls|rm|show [name]",
+		summary: "Manage saved views",
+		description: `
+A view saves the list flags under a name, run it with 'ghp <name>' or
+'ghp list -v <name>'. Flags given along a view override the saved ones.`,
+		examples: []string{
+			"ghp view save mywork -filter assignee:@me -exclude-column Done",
+			"ghp view ls",
+			"ghp view show mywork",
+			"ghp view rm mywork",
+		},
+		complete: []string{"save ls rm show", "@views"},
+		offline:  true,
+		flags:    listFlags,
+		run: func(env *cmdEnv, args []string) error {
+			return doView(env.state, args, env.filters, env.opts)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:     "help",
+		args:     "[command]",
+		summary:  "Show help for ghp or a command",
+		examples: []string{"ghp help", "ghp help list"},
+		complete: []string{"@commands"},
+		offline:  true,
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) == 0 {
+				printHelp(os.Stdout)
+				return nil
+			}
+			cmd := findCommand(args[0])
+			if cmd == nil {
+				return newError(errInvalidInput, "unknown command %v, see 'ghp help'", args[0])
+			}
+			printCommandHelp(os.Stdout, cmd)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	state := &ghpConfig{Views: map[string]savedView{
+		"mywork": {Filters: []string{"assignee:@me"}},
+		"bugs":   {Filters: []string{"label:bug"}},
+	}}
+	tests := []struct {
+		name       string
+		args       []string
+		command    string
+		view       string
+		positional []string
+		filters    filterFlags
+	}{
+		{"no command", nil, "list", "", []string{}, nil},
+		{"flags only", []string{"-v", "bugs"}, "list", "bugs", []string{}, filterFlags{"label:bug"}},
+		{"view as command", []string{"mywork"}, "list", "mywork", []string{}, filterFlags{"assignee:@me"}},
+		{"view with flags", []string{"mywork", "-sort", "number"}, "list", "mywork", []string{}, filterFlags{"assignee:@me"}},
+		{"-v wins over the command", []string{"mywork", "-v", "bugs"}, "list", "bugs", []string{}, filterFlags{"label:bug"}},
+		{"command", []string{"view", "ls"}, "view", "", []string{"ls"}, nil},
+	}
+	for _, test := range tests {
+		env := &cmdEnv{state: state, opts: new(listOptions)}
+		cmd, positional, err := parseCommandLine(env, test.args)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if cmd.name != test.command || env.viewName != test.view || !reflect.DeepEqual(positional, test.positional) {
+			t.Errorf("%v: got %v view %q %q, want %v view %q %q", test.name, cmd.name, env.viewName, positional,
+				test.command, test.view, test.positional)
+		}
+		if test.command != "list" {
+			continue
+		}
+		err = env.prepareList()
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(env.filters, test.filters) {
+			t.Errorf("%v: got filters %v, want %v", test.name, env.filters, test.filters)
+		}
+	}
+
+	for _, args := range [][]string{{"nope"}, {"list", "-nope"}} {
+		_, _, err := parseCommandLine(&cmdEnv{state: state, opts: new(listOptions)}, args)
+		if classifyError(err) != errInvalidInput {
+			t.Errorf("%v: got error %v, want invalid input", args, err)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// flagCompletions are the values completed after a flag, words and "@kind"
+// for the dynamic values of "ghp __complete kind"
+var flagCompletions = map[string]string{
+	"color":          "auto always never",
+	"theme":          "@themes",
+	"sort":           strings.Join(sortKeys, " "),
+	"group-by":       strings.Join(groupKeys, " "),
+	"column":         "@columns",
+	"columns":        "@columns",
+	"exclude-column": "@columns",
+	"start":          "@columns",
+	"done":           "@columns",
+	"position":       "top bottom",
+	"by":             strings.Join(staleActivities, " "),
+	"move":           "@columns",
+	"fail-on":        "none " + strings.Join(lintSeverities, " "),
+	"v":              "@views",
+	"filter":         "@refs",
+}
+
+// completionKinds are the dynamic values of "ghp __complete"
+var completionKinds = []string{"commands", "columns", "refs", "snapshots", "themes", "views"}
+
+// completionValues returns the dynamic values of kind, columns and refs come
+// from the board index of the last listing
+func completionValues(kind string, state *ghpConfig, cache *appCache) []string {
+	switch kind {
+	case "commands":
+		return commandNames()
+	case "columns":
+		return cache.index.Columns
+	case "refs":
+		return cache.index.Cards
+	case "themes":
+		names := []string{}
+		for name := range builtinThemes {
+			names = append(names, name)
+		}
+		for name := range state.Themes {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	case "views":
+		return viewNames(state)
+	case "snapshots":
+		return snapshotNames()
+	}
+	return nil
+}
+
+// commandWords returns the visible commands and their aliases
+func commandWords() []string {
+	words := []string{}
+	for _, name := range commandNames() {
+		words = append(words, name)
+		words = append(words, ghpCommands[name].aliases...)
+	}
+	return words
+}
+
+// commandFlags returns the flag names of cmd including the global ones, and
+// those taking a value
+func commandFlags(cmd *ghpCommand) ([]string, []string) {
+	names, valued := []string{}, []string{}
+	fs := newFlagSet(cmd, &cmdEnv{state: new(ghpConfig), opts: new(listOptions)}, true)
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+		if b, isBool := f.Value.(interface{ IsBoolFlag() bool }); !isBool || !b.IsBoolFlag() {
+			valued = append(valued, f.Name)
+		}
+	})
+	return names, valued
+}
+
+// bashWords returns a compgen word list, one word per line so column names
+// can have spaces, dynamic values call ghp
+func bashWords(completion string) string {
+	words := strings.Fields(completion)
+	for n, word := range words {
+		if strings.HasPrefix(word, "@") {
+			words[n] = "$(ghp __complete " + word[1:] + " 2>/dev/null)"
+		}
+	}
+	return strings.Join(words, "\n")
+}
+
+// bashValuedFlags returns the flags of every command followed by a value
+func bashValuedFlags() []string {
+	valued := []string{}
+	for _, name := range commandNames() {
+		_, flags := commandFlags(ghpCommands[name])
+		for _, f := range flags {
+			if !contains(valued, "-"+f) {
+				valued = append(valued, "-"+f, "--"+f)
+			}
+		}
+	}
+	sort.Strings(valued)
+	return valued
+}
+
+func writeBashCompletion(w io.Writer) {
+	defaultFlags := bashWords(strings.Join(mustFlags("list"), " "))
+	fmt.Fprintln(w, "# bash completion for ghp, load it with: source <(ghp completion bash)")
+	fmt.Fprintln(w, "_ghp() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    local IFS=$'\n' cmd="" pos=0 i words=""`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        case "${COMP_WORDS[i]}" in`)
+	fmt.Fprintln(w, `        -*) ;;`)
+	fmt.Fprintln(w, `        *)`)
+	fmt.Fprintln(w, `            case "${COMP_WORDS[i-1]}" in`)
+	fmt.Fprintf(w, "            %v) ;;\n", strings.Join(bashValuedFlags(), "|"))
+	fmt.Fprintln(w, `            *) if [ -z "$cmd" ]; then cmd="${COMP_WORDS[i]}"; else pos=$((pos + 1)); fi ;;`)
+	fmt.Fprintln(w, `            esac ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+
+	fmt.Fprintln(w, `    case "$prev" in`)
+	flagNames := []string{}
+	for f := range flagCompletions {
+		flagNames = append(flagNames, f)
+	}
+	sort.Strings(flagNames)
+	for _, f := range flagNames {
+		fmt.Fprintf(w, "    -%v|--%v) words=\"%v\" ;;\n", f, f, bashWords(flagCompletions[f]))
+	}
+	fmt.Fprintln(w, `    *)`)
+	fmt.Fprintln(w, `        case "$cmd" in`)
+	fmt.Fprintf(w, "        \"\") [[ \"$cur\" == -* ]] || words=\"%v\n$(ghp __complete views 2>/dev/null)\" ;;\n", bashWords(strings.Join(commandWords(), " ")))
+	for _, name := range commandNames() {
+		cmd := ghpCommands[name]
+		flags, _ := commandFlags(cmd)
+		fmt.Fprintf(w, "        %v)\n", strings.Join(append([]string{name}, cmd.aliases...), "|"))
+		fmt.Fprintf(w, "            if [[ \"$cur\" == -* ]]; then words=\"%v\"\n", bashWords(strings.Join(flags, " ")))
+		for n, completion := range cmd.complete {
+			fmt.Fprintf(w, "            elif [ $pos -eq %v ]; then words=\"%v\"\n", n, bashWords(completion))
+		}
+		fmt.Fprintln(w, "            fi ;;")
+	}
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintf(w, "        [[ \"$cur\" == -* ]] && [ -z \"$words\" ] && words=\"%v\" ;;\n", defaultFlags)
+	fmt.Fprintln(w, `    esac`)
+	io.WriteString(w, `    COMPREPLY=($(compgen -W "$words" -- "$cur" | while read -r word; do printf '%q\n' "$word"; done))`+"\n")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _ghp ghp")
+}
+
+// mustFlags returns the flags of a registered command
+func mustFlags(name string) []string {
+	flags, _ := commandFlags(ghpCommands[name])
+	return flags
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef ghp")
+	fmt.Fprintln(w, "# zsh completion for ghp, load it with: source <(ghp completion zsh)")
+	fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+	writeBashCompletion(w)
+}
+
+// fishWords returns the fish completion arguments of a word list
+func fishWords(completion string) string {
+	words := strings.Fields(completion)
+	for n, word := range words {
+		if strings.HasPrefix(word, "@") {
+			words[n] = "(ghp __complete " + word[1:] + " 2>/dev/null)"
+		}
+	}
+	return "'" + strings.Join(words, " ") + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for ghp, load it with: ghp completion fish | source")
+	fmt.Fprintln(w, "complete -c ghp -f")
+	names := commandNames()
+	fmt.Fprintln(w, "complete -c ghp -n '__fish_use_subcommand' -a '(ghp __complete views 2>/dev/null)' -d 'saved view'")
+	for _, name := range names {
+		for _, word := range append([]string{name}, ghpCommands[name].aliases...) {
+			fmt.Fprintf(w, "complete -c ghp -n '__fish_use_subcommand' -a %v -d %v\n", word, fishQuote(ghpCommands[name].summary))
+		}
+	}
+	for _, name := range names {
+		cmd := ghpCommands[name]
+		words := strings.Join(append([]string{name}, cmd.aliases...), " ")
+		condition := "'__fish_seen_subcommand_from " + words + "'"
+		if name == "list" {
+			condition = "'__fish_use_subcommand; or __fish_seen_subcommand_from " + words + "'"
+		}
+		fs := newFlagSet(cmd, &cmdEnv{state: new(ghpConfig), opts: new(listOptions)}, true)
+		fs.VisitAll(func(f *flag.Flag) {
+			line := fmt.Sprintf("complete -c ghp -n %v -o %v -d %v", condition, f.Name, fishQuote(f.Usage))
+			if completion, exists := flagCompletions[f.Name]; exists {
+				line += " -r -a " + fishWords(completion)
+			} else if b, isBool := f.Value.(interface{ IsBoolFlag() bool }); !isBool || !b.IsBoolFlag() {
+				line += " -r"
+			}
+			fmt.Fprintln(w, line)
+		})
+		for n, completion := range cmd.complete {
+			positionCondition := fmt.Sprintf("'__fish_seen_subcommand_from %v; and test (count (commandline -opc)) -eq %v'", words, n+2)
+			fmt.Fprintf(w, "complete -c ghp -n %v -a %v\n", positionCondition, fishWords(completion))
+		}
+	}
+}
+
+func fishQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `\'`) + "'"
+}
+
+func init() {
+	registerCommand(&ghpCommand{
+		name:    "completion",
+		args:    "bash|zsh|fish",
+		summary: "Print a shell completion script",
+		description: `
+Completes commands, flags, saved views, themes and, once the project has been
+listed, column names and repo#number card refs.`,
+		examples: []string{
+			"source <(ghp completion bash)",
+			"ghp completion zsh > \"${fpath[1]}/_ghp\"",
+			"ghp completion fish > ~/.config/fish/completions/ghp.fish",
+		},
+		complete: []string{"bash zsh fish"},
+		offline:  true,
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 1 {
+				return newError(errInvalidInput, "missing shell, use bash, zsh or fish")
+			}
+			switch args[0] {
+			case "bash":
+				writeBashCompletion(os.Stdout)
+			case "zsh":
+				writeZshCompletion(os.Stdout)
+			case "fish":
+				writeFishCompletion(os.Stdout)
+			default:
+				return newError(errInvalidInput, "unsupported shell %v, use bash, zsh or fish", args[0])
+			}
+			return nil
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:    "__complete",
+		args:    strings.Join(completionKinds, "|"),
+		summary: "Print completion values, used by the completion scripts",
+		hidden:  true,
+		offline: true,
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 1 || !contains(completionKinds, args[0]) {
+				return newError(errInvalidInput, "use one of %v", strings.Join(completionKinds, ", "))
+			}
+			for _, value := range completionValues(args[0], env.state, env.cache) {
+				fmt.Println(value)
+			}
+			return nil
+		},
+	})
+}
@@ -1,25 +1,31 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
-	"github.com/google/go-github/v32/github"
 	"github.com/mitchellh/go-homedir"
-	"golang.org/x/oauth2"
 )
 
 type ghpConfig struct {
-	AccessToken        string `json:"access_token"`
-	User               string `json:"user"`
-	DefaultProject     string `json:"default_project"`
-	DefaultProjectID   int64  `json:"default_project_id"`
-	DefaultProjectType string `json:"default_project_type"`
-	Organization       string `json:"organization"`
+	AccessToken        string               `json:"access_token"`
+	User               string               `json:"user"`
+	DefaultProject     string               `json:"default_project"`
+	DefaultProjectID   int64                `json:"default_project_id"`
+	DefaultProjectType string               `json:"default_project_type"`
+	Organization       string               `json:"organization"`
+	LabelStyle         string               `json:"label_style,omitempty"` // "background" (default) or "foreground"
+	Theme              string               `json:"theme,omitempty"`
+	Themes             map[string]theme     `json:"themes,omitempty"`
+	LabelPriority      []string             `json:"label_priority,omitempty"`
+	Views              map[string]savedView `json:"views,omitempty"`
+	CycleStartColumn   string               `json:"cycle_start_column,omitempty"`
+	CycleDoneColumn    string               `json:"cycle_done_column,omitempty"`
+	WIPLimits          map[string]int       `json:"wip_limits,omitempty"`
+	Lint               map[string]string    `json:"lint,omitempty"`
 }
 
 // load Loads json state from disk
@@ -70,26 +76,21 @@ func (state *ghpConfig) save() error {
 	return nil
 }
 
-// TODO: Use central client
-func renewConfig(state *ghpConfig) error {
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: state.AccessToken},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+func renewConfig(state *ghpConfig, c *ghpClient) error {
+	ctx := *c.context
+	client := c.apiClient
 	user, _, err := client.Users.Get(ctx, "")
 	if err != nil {
-		return fmt.Errorf("error getting user: %v", err)
+		return apiError(err, "error getting user")
 	}
 	fmt.Printf("Authenticated as: %v\n", user.GetLogin())
 	state.User = user.GetLogin()
 	orgs, _, err := client.Organizations.List(ctx, "", nil)
 	if err != nil {
-		return fmt.Errorf("error getting orgs for user %v : %v", state.User, err)
+		return apiError(err, "error getting orgs for user %v", state.User)
 	}
 	if len(orgs) == 0 {
-		return fmt.Errorf("no orgs for user %v", state.User)
+		return newError(errNotFound, "no orgs for user %v", state.User)
 	}
 	orgnames := []string{}
 	for _, org := range orgs {
@@ -102,10 +103,10 @@ func renewConfig(state *ghpConfig) error {
 	state.Organization = orgnames[index]
 	projects, _, err := client.Organizations.ListProjects(ctx, state.Organization, nil)
 	if err != nil {
-		return fmt.Errorf("error getting projects for org %v : %v", state.Organization, err)
+		return apiError(err, "error getting projects for org %v", state.Organization)
 	}
-	if len(orgs) == 0 {
-		return fmt.Errorf("no orgs for user %v", state.Organization)
+	if len(projects) == 0 {
+		return newError(errNotFound, "no projects for org %v", state.Organization)
 	}
 	projectList := []string{}
 	projectIDs := []int64{}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDashboardRefresh = time.Minute
+
+// dashboardHTML adds the filter form, auto refresh and, when writable, the
+// move form to the html report
+var dashboardHTML = template.Must(template.Must(htmlReport.Clone()).Parse(`
+{{- define "head"}}
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<style>
+.controls { margin: 0.5em 0; }
+.controls input { width: 20em; }
+.error { color: #cb2431; }
+</style>
+{{- end}}
+{{- define "controls"}}
+<form class="controls" method="get" action="/">
+{{- range .Filter}}
+<input name="filter" value="{{.}}" placeholder="filter, like label:bug,@me">
+{{- end}}
+<select name="view"><option value="">all cards</option>{{range .Views}}<option{{if eq . $.View}} selected{{end}}>{{.}}</option>{{end}}</select>
+<button>Apply</button>
+</form>
+{{- if .Writable}}
+<form class="controls" id="move">
+<input name="card" placeholder="card, like api#12" list="refs">
+<select name="column">{{range .Columns}}<option>{{.}}</option>{{end}}</select>
+<button>Move</button> <span id="moved" class="error"></span>
+</form>
+<datalist id="refs">{{range .Refs}}<option value="{{.}}">{{end}}</datalist>
+<script>
+document.getElementById("move").addEventListener("submit", function (event) {
+  event.preventDefault();
+  var form = event.target;
+  fetch("/api/move", {method: "POST", headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({card: form.card.value, column: form.column.value})})
+    .then(function (res) { return res.json(); })
+    .then(function (res) {
+      if (res.error) {
+        document.getElementById("moved").textContent = res.error;
+      } else {
+        location.reload();
+      }
+    });
+});
+</script>
+{{- else if .Locked}}
+<form class="controls" method="post" action="/unlock">
+<input name="token" type="password" placeholder="token to move cards">
+<button>Unlock</button>
+</form>
+{{- end}}
+{{- with .Error}}
+<p class="error">refresh failed: {{.}}</p>
+{{- end}}
+{{- end}}
+`))
+
+// dashboardPage is the report of the dashboard with its controls
+type dashboardPage struct {
+	*report
+	Filter   []string
+	Views    []string
+	Columns  []string
+	Refs     []string
+	Refresh  int // seconds
+	Writable bool
+	Locked   bool // writable once the token is given
+	Error    string
+}
+
+// dashboardColumn is a column in /api/columns
+type dashboardColumn struct {
+	Name  string `json:"name"`
+	ID    int64  `json:"id"`
+	Cards int    `json:"cards"`
+	WIP   int    `json:"wip"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// dashboardMove is the body of /api/move
+type dashboardMove struct {
+	Card     string `json:"card"`
+	Column   string `json:"column"`
+	Position string `json:"position"`
+	Force    bool   `json:"force"`
+}
+
+// dashboard serves the last loaded board as a web page, json and metrics
+type dashboard struct {
+	env      *cmdEnv
+	writable bool
+	token    string // needed to move cards when set
+	refresh  time.Duration
+
+	mu          sync.RWMutex
+	board       *ProjectProxy
+	loaded      time.Time
+	err         error // of the last refresh
+	cacheHits   int
+	cacheMisses int
+}
+
+// setBoard replaces the board, also called by the webhook server right
+// after loading it
+func (d *dashboard) setBoard(p *ProjectProxy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.board, d.loaded, d.err = p, time.Now(), nil
+	d.cacheHits, d.cacheMisses = d.env.cache.cacheHits, d.env.cache.cacheMiss
+}
+
+func (d *dashboard) load() error {
+	p, err := loadProject(*d.env.state, d.env.cache, d.env.client, &listOptions{quiet: true})
+	if err != nil {
+		d.mu.Lock()
+		d.err = err
+		d.mu.Unlock()
+		return err
+	}
+	d.setBoard(p)
+	return nil
+}
+
+// loop reloads the board every refresh until ctx is done, the board cache
+// answers while a webhook server runs
+func (d *dashboard) loop(ctx context.Context) {
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.load()
+			if err != nil && ctx.Err() == nil {
+				serveLogf("error refreshing the dashboard: %v", err)
+			}
+		}
+	}
+}
+
+// listing reads the filters, view and list options of a request like the
+// list flags
+func (d *dashboard) listing(r *http.Request) (*cmdEnv, error) {
+	q := r.URL.Query()
+	env := &cmdEnv{state: d.env.state, opts: &listOptions{quiet: true}, viewName: q.Get("view")}
+	for _, filter := range q["filter"] {
+		if strings.TrimSpace(filter) != "" {
+			env.filters = append(env.filters, filter)
+		}
+	}
+	for _, name := range q["column"] {
+		err := (*columnFlags)(&env.opts.columns).Set(name)
+		if err != nil {
+			return nil, newError(errInvalidInput, "invalid column %q: %v", name, err)
+		}
+	}
+	for _, name := range q["exclude-column"] {
+		err := (*columnFlags)(&env.opts.excludeColumns).Set(name)
+		if err != nil {
+			return nil, newError(errInvalidInput, "invalid exclude-column %q: %v", name, err)
+		}
+	}
+	env.opts.sortBy = q.Get("sort")
+	env.opts.groupBy = q.Get("group-by")
+	return env, env.prepareList()
+}
+
+// loopbackAddress tells if a listen address is only reachable from this
+// machine, an empty host listens on every interface
+func loopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// dashboardCookie keeps the token of the page after unlocking it
+const dashboardCookie = "ghp_token"
+
+// authorized tells if a request may move cards, with the token as a bearer
+// header or, for the page, in the cookie set by /unlock
+func (d *dashboard) authorized(r *http.Request) bool {
+	if d.token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if cookie, err := r.Cookie(dashboardCookie); token == "" && err == nil {
+		token = cookie.Value
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+}
+
+// unlock checks the token posted by the page and keeps it in a cookie only
+// sent by the page itself, it never goes in urls
+func (d *dashboard) unlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST the token", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.PostFormValue("token")
+	if !d.writable || d.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) != 1 {
+		http.Error(w, "wrong token", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Value: token, Path: "/", HttpOnly: true,
+		Secure: r.TLS != nil, SameSite: http.SameSiteStrictMode})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// httpStatus maps error kinds to http statuses
+func httpStatus(err error) int {
+	switch classifyError(err) {
+	case errInvalidInput:
+		return http.StatusBadRequest
+	case errNotFound:
+		return http.StatusNotFound
+	case errCheckFailed:
+		return http.StatusConflict
+	case errAuth:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, err error) {
+	writeJSON(w, httpStatus(err), map[string]string{"error": err.Error()})
+}
+
+// current returns the board for reading, call the returned func when done
+func (d *dashboard) current(w http.ResponseWriter) (*ProjectProxy, func()) {
+	d.mu.RLock()
+	if d.board == nil {
+		d.mu.RUnlock()
+		http.Error(w, "the board isn't loaded yet", http.StatusServiceUnavailable)
+		return nil, nil
+	}
+	w.Header().Set("Last-Modified", d.loaded.UTC().Format(http.TimeFormat))
+	return d.board, d.mu.RUnlock
+}
+
+func (d *dashboard) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	env, err := d.listing(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, done := d.current(w)
+	if p == nil {
+		return
+	}
+	defer done()
+	state := *d.env.state
+	page := &dashboardPage{
+		report:   buildReport(state, p, env.filters, env.viewName, env.opts),
+		Filter:   r.URL.Query()["filter"],
+		Views:    viewNames(&state),
+		Refresh:  int(d.refresh.Seconds()),
+		Writable: d.writable && d.authorized(r),
+		Locked:   d.writable && !d.authorized(r),
+	}
+	if len(page.Filter) == 0 {
+		page.Filter = []string{""}
+	}
+	if d.err != nil {
+		page.Error = d.err.Error()
+	}
+	for _, col := range p.columns {
+		page.Columns = append(page.Columns, col.name)
+		for _, c := range col.cards {
+			if i := cardIssue(c); i != nil {
+				page.Refs = append(page.Refs, i.ref())
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = dashboardHTML.Execute(w, page)
+	if err != nil {
+		serveLogf("error rendering the dashboard: %v", err)
+	}
+}
+
+func (d *dashboard) columns(w http.ResponseWriter, r *http.Request) {
+	p, done := d.current(w)
+	if p == nil {
+		return
+	}
+	defer done()
+	columns := []dashboardColumn{}
+	for n := range p.columns {
+		col := &p.columns[n]
+		columns = append(columns, dashboardColumn{Name: col.name, ID: col.id, Cards: len(col.cards), WIP: col.wip(), Limit: col.limit})
+	}
+	writeJSON(w, http.StatusOK, columns)
+}
+
+func (d *dashboard) cards(w http.ResponseWriter, r *http.Request) {
+	env, err := d.listing(r)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	p, done := d.current(w)
+	if p == nil {
+		return
+	}
+	defer done()
+	filters := env.filters.toFilters(d.env.state.User)
+	cards := []cardState{}
+	for _, col := range p.columns {
+		if !env.opts.showColumn(col.name) {
+			continue
+		}
+		for n, c := range col.cards {
+			if c.match(filters) {
+				cards = append(cards, newCardState(col, n, c))
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, cards)
+}
+
+// move moves a card and places it in the loaded board, the next refresh or
+// webhook delivery brings the rest. Only json bodies are accepted so other
+// sites can't post forms here
+func (d *dashboard) move(w http.ResponseWriter, r *http.Request) {
+	if !d.writable {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "the dashboard is read only, start it with -write"})
+		return
+	}
+	if !d.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong token, send it as Authorization: Bearer"})
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST a json body"})
+		return
+	}
+	req := dashboardMove{Position: "top"}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if err != nil {
+		writeJSONError(w, newError(errInvalidInput, "invalid body: %v", err))
+		return
+	}
+	if req.Position != "top" && req.Position != "bottom" {
+		writeJSONError(w, newError(errInvalidInput, "invalid position %v, use top or bottom", req.Position))
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.board == nil {
+		http.Error(w, "the board isn't loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+	c, from, err := findCard(d.board, req.Card)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	to, err := findColumn(d.board, req.Column)
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	if msg := wipExceeded(c, from, to); msg != "" && !req.Force {
+		writeJSONError(w, newError(errCheckFailed, "%v, set force to move it anyway", msg))
+		return
+	}
+	id, err := cardID(c)
+	if err == nil {
+		err = d.env.client.moveCard(id, req.Position, to.id)
+	}
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+	for n := range from.cards {
+		if from.cards[n] == c {
+			from.cards = append(from.cards[:n], from.cards[n+1:]...)
+			break
+		}
+	}
+	if req.Position == "top" {
+		to.cards = append([]card{c}, to.cards...)
+	} else {
+		to.cards = append(to.cards, c)
+	}
+	serveLogf("moved %v from %v to %v from the dashboard", cardName(c), from.name, to.name)
+	writeJSON(w, http.StatusOK, map[string]string{"moved": cardName(c), "from": from.name, "to": to.name})
+}
+
+func (d *dashboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.page)
+	mux.HandleFunc("/api/columns", d.columns)
+	mux.HandleFunc("/api/cards", d.cards)
+	mux.HandleFunc("/api/move", d.move)
+	mux.HandleFunc("/unlock", d.unlock)
+	return mux
+}
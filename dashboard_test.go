@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// dashboardIssue is an open issue of api on the card with the given id
+func dashboardIssue(number int, id int64, labels ...string) *issue {
+	i := &issue{url: fmt.Sprintf("https://api.github.com/projects/columns/cards/%v", id)}
+	i.repository = &github.Repository{Name: github.String("api")}
+	i.ghIssue = &github.Issue{Number: github.Int(number), State: github.String("open")}
+	for _, label := range labels {
+		i.ghIssue.Labels = append(i.ghIssue.Labels, &github.Label{Name: github.String(label)})
+	}
+	return i
+}
+
+// testDashboard serves a board with api#1 and api#2 to do and api#3 done,
+// where the wip limit is 1. Card moves go to a fake api counting them
+func testDashboard(t *testing.T, writable bool) (*dashboard, *int) {
+	testHome(t)
+	moves := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || !strings.HasSuffix(r.URL.Path, "/moves") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		moves++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(api.Close)
+	client := createClient(context.Background(), "x")
+	client.apiClient.BaseURL, _ = url.Parse(api.URL + "/")
+	d := &dashboard{env: &cmdEnv{state: &ghpConfig{}, cache: initCache(), client: client}, writable: writable}
+	d.setBoard(&ProjectProxy{columns: []column{
+		{name: "To do", id: 1, cards: []card{dashboardIssue(1, 11, "bug"), dashboardIssue(2, 12)}},
+		{name: "Done", id: 2, limit: 1, cards: []card{dashboardIssue(3, 13)}},
+	}})
+	return d, &moves
+}
+
+func serveDashboard(d *dashboard, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	d.handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestDashboardAPI(t *testing.T) {
+	d, _ := testDashboard(t, false)
+	w := serveDashboard(d, "GET", "/api/columns", "")
+	columns := []dashboardColumn{}
+	json.Unmarshal(w.Body.Bytes(), &columns)
+	want := []dashboardColumn{{Name: "To do", ID: 1, Cards: 2, WIP: 2}, {Name: "Done", ID: 2, Cards: 1, WIP: 1, Limit: 1}}
+	if w.Code != http.StatusOK || fmt.Sprint(columns) != fmt.Sprint(want) {
+		t.Errorf("columns: got %v %v, want %v", w.Code, columns, want)
+	}
+
+	tests := []struct {
+		query string
+		refs  []string
+	}{
+		{"", []string{"api#1", "api#2", "api#3"}},
+		{"?filter=label:bug", []string{"api#1"}},
+		{"?column=done", []string{"api#3"}},
+		{"?exclude-column=done", []string{"api#1", "api#2"}},
+	}
+	for _, test := range tests {
+		w := serveDashboard(d, "GET", "/api/cards"+test.query, "")
+		cards := []cardState{}
+		json.Unmarshal(w.Body.Bytes(), &cards)
+		refs := []string{}
+		for _, c := range cards {
+			refs = append(refs, c.Ref)
+		}
+		if w.Code != http.StatusOK || strings.Join(refs, " ") != strings.Join(test.refs, " ") {
+			t.Errorf("cards%v: got %v %v, want %v", test.query, w.Code, refs, test.refs)
+		}
+	}
+}
+
+func TestDashboardMove(t *testing.T) {
+	d, _ := testDashboard(t, false)
+	if w := serveDashboard(d, "POST", "/api/move", `{"card": "api#1", "column": "Done"}`); w.Code != http.StatusForbidden {
+		t.Errorf("read only: got status %v, want %v", w.Code, http.StatusForbidden)
+	}
+
+	d, moves := testDashboard(t, true)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"GET", "GET", "", http.StatusMethodNotAllowed},
+		{"not json", "POST", "card=api#1", http.StatusBadRequest},
+		{"bad position", "POST", `{"card": "api#1", "column": "Done", "position": "middle"}`, http.StatusBadRequest},
+		{"unknown card", "POST", `{"card": "api#9", "column": "Done"}`, http.StatusNotFound},
+		{"unknown column", "POST", `{"card": "api#1", "column": "Nowhere"}`, http.StatusNotFound},
+		{"over the wip limit", "POST", `{"card": "api#1", "column": "Done"}`, http.StatusConflict},
+		{"forced", "POST", `{"card": "api#1", "column": "Done", "force": true}`, http.StatusOK},
+		{"back to the bottom", "POST", `{"card": "api#1", "column": "To do", "position": "bottom"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		w := serveDashboard(d, test.method, "/api/move", test.body)
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, want %v: %v", test.name, w.Code, test.status, w.Body.String())
+		}
+	}
+	if *moves != 2 {
+		t.Errorf("got %v moves, want 2", *moves)
+	}
+	refs := []string{}
+	for _, c := range d.board.columns[0].cards {
+		refs = append(refs, cardName(c))
+	}
+	if strings.Join(refs, " ") != "api#2 api#1" {
+		t.Errorf("got To do %v, want api#2 api#1", refs)
+	}
+}
+
+func TestLoopbackAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		loopback bool
+	}{
+		{"localhost:8000", true},
+		{"127.0.0.1:8000", true},
+		{"[::1]:8000", true},
+		{":8000", false},
+		{"0.0.0.0:8000", false},
+		{"192.168.1.10:8000", false},
+		{"ci.example.com:8000", false},
+		{"localhost", false},
+	}
+	for _, test := range tests {
+		if loopback := loopbackAddress(test.addr); loopback != test.loopback {
+			t.Errorf("%v: got loopback %v, want %v", test.addr, loopback, test.loopback)
+		}
+	}
+}
+
+func TestDashboardMoveToken(t *testing.T) {
+	d, moves := testDashboard(t, true)
+	d.token = "t0ken"
+	tests := []struct {
+		name   string
+		target string
+		header string
+		cookie string
+		status int
+	}{
+		{"missing", "/api/move", "", "", http.StatusUnauthorized},
+		{"wrong", "/api/move", "Bearer other", "", http.StatusUnauthorized},
+		{"in the query", "/api/move?token=t0ken", "", "", http.StatusUnauthorized},
+		{"wrong cookie", "/api/move", "", "other", http.StatusUnauthorized},
+		{"header", "/api/move", "Bearer t0ken", "", http.StatusOK},
+		{"cookie", "/api/move", "", "t0ken", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.target, strings.NewReader(`{"card": "api#1", "column": "To do", "position": "bottom"}`))
+		r.Header.Set("Content-Type", "application/json")
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: dashboardCookie, Value: test.cookie})
+		}
+		w := httptest.NewRecorder()
+		d.handler().ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, want %v: %v", test.name, w.Code, test.status, w.Body.String())
+		}
+	}
+	if *moves != 2 {
+		t.Errorf("got %v moves, want 2", *moves)
+	}
+}
+
+func TestDashboardUnlock(t *testing.T) {
+	d, _ := testDashboard(t, true)
+	d.token = "t0ken"
+	page := func(cookies ...*http.Cookie) string {
+		r := httptest.NewRequest("GET", "/?filter=label:bug", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		d.handler().ServeHTTP(w, r)
+		if strings.Contains(w.Body.String(), "t0ken") {
+			t.Errorf("the page has the token")
+		}
+		return w.Body.String()
+	}
+	if body := page(); !strings.Contains(body, `action="/unlock"`) || strings.Contains(body, `id="move"`) {
+		t.Errorf("a locked page doesn't ask for the token")
+	}
+
+	unlock := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/unlock", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		d.handler().ServeHTTP(w, r)
+		return w
+	}
+	if w := unlock("other"); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("wrong token: got status %v and cookies %v", w.Code, w.Result().Cookies())
+	}
+	w := unlock("t0ken")
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusSeeOther || len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("right token: got status %v and cookies %v", w.Code, cookies)
+	}
+	if body := page(cookies...); !strings.Contains(body, `id="move"`) || strings.Contains(body, `action="/unlock"`) {
+		t.Errorf("an unlocked page has no move form")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// errorKind classifies errors, every kind has its own exit code
+type errorKind int
+
+const (
+	errGeneric errorKind = iota
+	errInvalidInput
+	errAuth
+	errNotFound
+	errRateLimited
+	errNetwork
+	errCheckFailed
+	errInterrupted
+)
+
+// exit codes by error kind, documented in README
+var exitCodes = map[errorKind]int{
+	errGeneric:      1,
+	errInvalidInput: 2,
+	errAuth:         3,
+	errNotFound:     4,
+	errRateLimited:  5,
+	errNetwork:      6,
+	errCheckFailed:  7,
+	errInterrupted:  130,
+}
+
+// ghpError is an error with a kind, err is the original cause
+type ghpError struct {
+	kind errorKind
+	msg  string
+	err  error
+}
+
+func (e *ghpError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	if e.msg == "" {
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *ghpError) Unwrap() error {
+	return e.err
+}
+
+// newError returns an error of kind without cause
+func newError(kind errorKind, format string, args ...interface{}) error {
+	return &ghpError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// apiError wraps an api error with a message, its kind comes from the cause
+func apiError(err error, format string, args ...interface{}) error {
+	return &ghpError{kind: classifyError(err), msg: fmt.Sprintf(format, args...), err: err}
+}
+
+// statusError returns an error for an unexpected http status
+func statusError(res *http.Response, format string, args ...interface{}) error {
+	return &ghpError{kind: statusKind(res), msg: fmt.Sprintf(format, args...) + ": http: " + res.Status}
+}
+
+func statusKind(res *http.Response) errorKind {
+	kind := errGeneric
+	switch res.StatusCode {
+	case http.StatusUnauthorized:
+		kind = errAuth
+	case http.StatusNotFound:
+		kind = errNotFound
+	case http.StatusTooManyRequests:
+		kind = errRateLimited
+	case http.StatusForbidden:
+		kind = errAuth
+		if res.Header.Get("X-RateLimit-Remaining") == "0" {
+			kind = errRateLimited
+		}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		kind = errInvalidInput
+	}
+	if res.StatusCode >= 500 {
+		kind = errNetwork
+	}
+	return kind
+}
+
+// classifyError guesses the kind of errors coming from the github client,
+// the http stack or the command context
+func classifyError(err error) errorKind {
+	var typed *ghpError
+	if errors.As(err, &typed) {
+		return typed.kind
+	}
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return errRateLimited
+	}
+	if errors.Is(err, context.Canceled) {
+		return errInterrupted
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errNetwork
+	}
+	var resErr *github.ErrorResponse
+	if errors.As(err, &resErr) && resErr.Response != nil {
+		return statusKind(resErr.Response)
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return errNetwork
+	}
+	return errGeneric
+}
+
+func exitCode(err error) int {
+	return exitCodes[classifyError(err)]
+}
+
+// fail prints err on stderr and exits with its code
+func fail(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "\ninterrupted")
+	} else {
+		fmt.Fprintf(os.Stderr, "ghp: %v\n", err)
+	}
+	os.Exit(exitCode(err))
+}
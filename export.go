@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// repoMapFlags is a repeatable -map-repo from=to flag, repos are "repo" or
+// "owner/repo"
+type repoMapFlags map[string]string
+
+func (m repoMapFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("use from=to, got %v", value)
+	}
+	m[parts[0]] = parts[1]
+	return nil
+}
+
+func (m repoMapFlags) String() string {
+	pairs := []string{}
+	for from, to := range m {
+		pairs = append(pairs, from+"="+to)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// mapContentURL changes the repository of an issue api url following the
+// mapping, "owner/repo" entries are checked before "repo" ones
+func mapContentURL(url string, mapping repoMapFlags) string {
+	parts := strings.Split(url, "/")
+	for n, part := range parts {
+		if part != "repos" || n+2 >= len(parts) {
+			continue
+		}
+		owner, repo := parts[n+1], parts[n+2]
+		if to, exists := mapping[owner+"/"+repo]; exists {
+			if slash := strings.Index(to, "/"); slash != -1 {
+				parts[n+1], parts[n+2] = to[:slash], to[slash+1:]
+			} else {
+				parts[n+2] = to
+			}
+		} else if to, exists := mapping[repo]; exists {
+			parts[n+2] = to
+		}
+		break
+	}
+	return strings.Join(parts, "/")
+}
+
+// urlRef returns "repo#number" for an issue api url
+func urlRef(url string) string {
+	parts := strings.Split(url, "/")
+	if len(parts) < 3 {
+		return url
+	}
+	return parts[len(parts)-3] + "#" + parts[len(parts)-1]
+}
+
+// importOptions are the import flags
+type importOptions struct {
+	into    string
+	dryRun  bool
+	mapRepo repoMapFlags
+}
+
+// importStep is a step of the import plan. Cards are added when content is
+// set or they are notes, the others are kept or skipped for reason
+type importStep struct {
+	action  string
+	column  string
+	card    cardState
+	content *github.ProjectCardOptions
+	reason  string
+}
+
+const (
+	stepCreateColumn = "create column"
+	stepAdd          = "add"
+	stepKeep         = "keep"
+	stepSkip         = "skip"
+)
+
+func (s importStep) String() string {
+	switch s.action {
+	case stepCreateColumn:
+		return fmt.Sprintf("%v %v", s.action, s.column)
+	case stepAdd:
+		return fmt.Sprintf("%v %v to %v", s.action, s.card.Ref, s.column)
+	}
+	return fmt.Sprintf("%v %v: %v", s.action, s.card.Ref, s.reason)
+}
+
+// resolveProject finds a project of the configured organization by id or
+// name
+func resolveProject(state *ghpConfig, client *ghpClient, name string) (int64, string, error) {
+	if name == "" {
+		return state.DefaultProjectID, state.DefaultProject, nil
+	}
+	projects, err := client.listProjects(state.Organization)
+	if err != nil {
+		return 0, "", err
+	}
+	id, _ := strconv.ParseInt(name, 10, 64)
+	for _, project := range projects {
+		if project.GetID() == id || strings.EqualFold(project.GetName(), name) {
+			return project.GetID(), project.GetName(), nil
+		}
+	}
+	return 0, "", newError(errNotFound, "no project %v in %v", name, state.Organization)
+}
+
+// readSnapshotFile reads an exported board, "-" reads stdin
+func readSnapshotFile(path string) (*snapshot, error) {
+	if path != "-" {
+		return loadSnapshot(path)
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	s := new(snapshot)
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, newError(errInvalidInput, "invalid board: %v", err)
+	}
+	return s, nil
+}
+
+// planImport compares the exported board with the target project columns
+// and cards, cards already in the project are kept and issues missing in the
+// target are skipped
+func planImport(client *ghpClient, board *snapshot, projectID int64, opts *importOptions) ([]importStep, map[string]int64, error) {
+	cols, err := client.listColumns(projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	columnIDs := make(map[string]int64)
+	existing := make(map[string]bool)
+	for _, col := range cols {
+		columnIDs[strings.ToLower(col.GetName())] = col.GetID()
+		cards, err := client.getAllColumnCards(col.GetID())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range cards {
+			if c.GetNote() != "" {
+				existing[strings.ToLower(col.GetName())+"\n"+c.GetNote()] = true
+			} else {
+				existing[c.GetContentURL()] = true
+			}
+		}
+	}
+
+	steps := []importStep{}
+	for _, name := range board.Columns {
+		if _, exists := columnIDs[strings.ToLower(name)]; !exists {
+			steps = append(steps, importStep{action: stepCreateColumn, column: name})
+		}
+	}
+	for _, c := range board.Cards {
+		step := importStep{action: stepAdd, column: c.Column, card: c}
+		if c.Type == "note" {
+			if existing[strings.ToLower(c.Column)+"\n"+c.Note] {
+				step.action, step.reason = stepKeep, "already in "+c.Column
+			} else {
+				step.content = &github.ProjectCardOptions{Note: c.Note}
+			}
+			steps = append(steps, step)
+			continue
+		}
+		url := mapContentURL(c.ContentURL, opts.mapRepo)
+		if url != c.ContentURL {
+			step.card.Ref = c.Ref + " as " + urlRef(url)
+		}
+		if existing[url] {
+			step.action, step.reason = stepKeep, "already in the project"
+			steps = append(steps, step)
+			continue
+		}
+		content, err := cardContent(client, url)
+		if err != nil {
+			if classifyError(err) != errNotFound {
+				return nil, nil, err
+			}
+			step.action, step.reason = stepSkip, "not found in the target"
+		}
+		step.content = content
+		steps = append(steps, step)
+	}
+	return steps, columnIDs, nil
+}
+
+// cardContent returns the card options for an issue or pull request url
+func cardContent(client *ghpClient, url string) (*github.ProjectCardOptions, error) {
+	i := new(github.Issue)
+	err := client.getAPIObject(url, i)
+	if err != nil {
+		return nil, err
+	}
+	if !i.IsPullRequest() {
+		return &github.ProjectCardOptions{ContentID: i.GetID(), ContentType: "Issue"}, nil
+	}
+	pull := new(github.PullRequest)
+	err = client.getAPIObject(i.GetPullRequestLinks().GetURL(), pull)
+	if err != nil {
+		return nil, err
+	}
+	return &github.ProjectCardOptions{ContentID: pull.GetID(), ContentType: "PullRequest"}, nil
+}
+
+// applyImport runs the plan, added cards are moved to the bottom of their
+// column to keep the exported order
+func applyImport(client *ghpClient, projectID int64, steps []importStep, columnIDs map[string]int64) error {
+	for _, step := range steps {
+		switch step.action {
+		case stepCreateColumn:
+			col, err := client.createColumn(projectID, step.column)
+			if err != nil {
+				return err
+			}
+			columnIDs[strings.ToLower(step.column)] = col.GetID()
+		case stepAdd:
+			columnID := columnIDs[strings.ToLower(step.column)]
+			card, err := client.createCard(columnID, step.content)
+			if err != nil {
+				return err
+			}
+			err = client.moveCard(card.GetID(), "bottom", columnID)
+			if err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		fmt.Println(step.String())
+	}
+	return nil
+}
+
+func doImport(env *cmdEnv, opts *importOptions, args []string) error {
+	if len(args) != 1 {
+		return newError(errInvalidInput, "use 'ghp import <board.json> [-into project]'")
+	}
+	err := checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	board, err := readSnapshotFile(args[0])
+	if err != nil {
+		return err
+	}
+	projectID, project, err := resolveProject(env.state, env.client, opts.into)
+	if err != nil {
+		return err
+	}
+	steps, columnIDs, err := planImport(env.client, board, projectID, opts)
+	if err != nil {
+		return err
+	}
+	if opts.dryRun {
+		fmt.Printf("Importing %v into %v would:\n", board.Project, project)
+		for _, step := range steps {
+			fmt.Println("  " + step.String())
+		}
+		return nil
+	}
+	err = applyImport(env.client, projectID, steps, columnIDs)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if step.action == stepSkip {
+			fmt.Println(step.String())
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerCommand(&ghpCommand{
+		name:    "export",
+		summary: "Print the default project as json",
+		description: `
+The export has the columns, card order, notes and the issues and pull
+requests of the cards, it's the same format as snapshots.`,
+		examples: []string{"ghp export > board.json"},
+		run: func(env *cmdEnv, args []string) error {
+			board, err := liveSnapshot(*env.state, env.cache, env.client, env.opts.quiet)
+			if err != nil {
+				return err
+			}
+			board.Name = "export"
+			data, err := json.MarshalIndent(board, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	})
+	opts := &importOptions{mapRepo: make(repoMapFlags)}
+	registerCommand(&ghpCommand{
+		name:    "import",
+		args:    "<board.json>",
+		summary: "Recreate an exported board in a project",
+		description: `
+Creates the missing columns and adds the cards in their exported order to the
+default project or the one given with -into, by name or id. Cards already in
+the project are kept, issues that don't exist in the target are skipped and
+-map-repo takes issues from another repository with the same numbers, like
+a sprint template. Use - to read the board from stdin.`,
+		examples: []string{
+			"ghp import board.json -into 'Sprint 12' -dry-run",
+			"ghp import template.json -into 'Sprint 12' -map-repo templates=api",
+			"ghp export | ghp import - -into 'Board backup'",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			fs.StringVar(&opts.into, "into", "", "Target project name or id, the default project if not given")
+			fs.BoolVar(&opts.dryRun, "dry-run", false, "Print the plan without changing anything")
+			fs.Var(opts.mapRepo, "map-repo", "Take issues from another repository, as from=to, can be repeated")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			return doImport(env, opts, args)
+		},
+	})
+}
@@ -15,9 +15,17 @@ func max(a, b int) int {
 	return b
 }
 
+// ellipseStr cuts str to size runes ending in "...", sizes too small for the
+// dots just cut it
 func ellipseStr(str string, size int) string {
 	runes := bytes.Runes([]byte(str))
+	if size <= 0 {
+		return ""
+	}
 	if len(runes) > size {
+		if size <= 3 {
+			return string(runes[:size])
+		}
 		return string(runes[:size-3]) + "..."
 	}
 	return string(runes)
@@ -25,23 +33,157 @@ func ellipseStr(str string, size int) string {
 
 func fancyNoteStr(n *note, idField, maxSize int) string {
 	str := ""
-	idSp := strings.Repeat(" ", idField-utf8.RuneCountInString("note:"))
+	idSp := strings.Repeat(" ", max(idField-utf8.RuneCountInString("note:"), 0))
 	noteText := strings.Split(n.text, "\n")[0]
-	remain := maxSize - idField
+	remain := max(maxSize-idField, 0)
 	noteText = ellipseStr(noteText, remain)
-	str = "  note:" + idSp + noteText
+	str = "  note:" + idSp + singleColorHub.noteColorize(noteText)
 	return str
 }
 
-func fancyIssueStr(i *issue, idField, asigneeField, maxSize int) string {
+// prMarkers returns the pull request status markers, plain for measuring and
+// colored for printing: review decision, CI state, conflicts and draft
+func prMarkers(pr *pullRequest) (string, string) {
+	th := singleColorHub.theme
+	review := map[string]string{
+		reviewApproved:         singleColorHub.styled(th.Ok, "✓"),
+		reviewChangesRequested: singleColorHub.styled(th.Fail, "✗"),
+		reviewRequired:         singleColorHub.styled(th.Warn, "?"),
+		reviewNone:             singleColorHub.styled(th.Muted, "·"),
+	}[pr.reviewDecision]
+	ci := map[string]string{
+		ciPassing: singleColorHub.styled(th.Ok, "●"),
+		ciFailing: singleColorHub.styled(th.Fail, "●"),
+		ciPending: singleColorHub.styled(th.Warn, "●"),
+		ciNone:    singleColorHub.styled(th.Muted, "·"),
+	}[pr.ciState]
+	plain := "[xx"
+	colored := "[" + review + ci
+	if pr.conflicting() {
+		plain += "!"
+		colored += singleColorHub.styled(th.Fail, "!")
+	}
+	if pr.ghPull.GetDraft() {
+		plain += " draft"
+		colored += singleColorHub.styled(th.Muted, " draft")
+	}
+	return plain + "] ", colored + "] "
+}
+
+// listLayout holds the width of every fixed field of the list, optional fields
+// get zero width when the console is too narrow for them
+type listLayout struct {
+	id        int
+	assignees int
+	linked    int
+	milestone int
+	comments  int
+	maxSize   int
+}
+
+// max widths of the optional fields, longer values get ellipsed
+const (
+	maxAssigneesField = 30
+	maxLinkedField    = 16
+	maxMilestoneField = 18
+	minTitleField     = 24
+)
+
+// fixedWidth is the width used by the optional fields and their separators
+func (l *listLayout) fixedWidth() int {
+	width := 0
+	for _, field := range []int{l.linked, l.milestone, l.comments} {
+		if field > 0 {
+			width += field + 1
+		}
+	}
+	return width
+}
+
+// fit drops optional fields, least important first, until the title keeps
+// at least minTitleField runes
+func (l *listLayout) fit() {
+	fields := []*int{&l.linked, &l.milestone, &l.comments}
+	for _, field := range fields {
+		if l.maxSize-l.id-l.assignees-l.fixedWidth() >= minTitleField {
+			return
+		}
+		*field = 0
+	}
+}
+
+// padLeft right aligns an already colored string of plainLen visible runes
+func padLeft(str string, plainLen, width int) string {
+	if plainLen >= width {
+		return str
+	}
+	return strings.Repeat(" ", width-plainLen) + str
+}
+
+// assigneesStr returns plain and colored "@a,@b" strings, assignees that
+// don't fit in maxAssigneesField are summarized as "+n"
+func assigneesStr(i *issue) (string, string) {
+	logins := i.assigneeLogins()
+	plain := ""
+	colored := ""
+	for n, login := range logins {
+		sep := ""
+		if n > 0 {
+			sep = ","
+		}
+		rest := ""
+		if n < len(logins)-1 {
+			rest = fmt.Sprintf(",+%v", len(logins)-n-1)
+		}
+		if utf8.RuneCountInString(plain+sep+"@"+login+rest) > maxAssigneesField && n > 0 {
+			more := fmt.Sprintf("+%v", len(logins)-n)
+			return plain + sep + more, colored + sep + more
+		}
+		plain += sep + "@" + login
+		colored += sep + singleColorHub.assigneeColorize("@"+login)
+	}
+	return plain, colored
+}
+
+func linkedStr(i *issue) string {
+	if len(i.linkedPulls) == 0 {
+		return ""
+	}
+	return ellipseStr("⇄"+strings.Join(i.linkedPulls, ","), maxLinkedField)
+}
+
+func milestoneStr(i *issue) string {
+	if i.ghIssue.Milestone == nil {
+		return ""
+	}
+	return ellipseStr("⚑"+i.ghIssue.GetMilestone().GetTitle(), maxMilestoneField)
+}
+
+func commentsStr(i *issue) string {
+	if i.ghIssue.GetComments() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("✉%v", i.ghIssue.GetComments())
+}
+
+func fancyPullRequestStr(pr *pullRequest, layout *listLayout) string {
+	plain, colored := prMarkers(pr)
+	return fancyIssueStr(&pr.issue, plain, colored, layout)
+}
+
+// fancyIssueStr formats an issue line, markers are printed before the title
+// and take the width of plainMarkers
+func fancyIssueStr(i *issue, plainMarkers, markers string, layout *listLayout) string {
 	//log.Printf("fancy str for %v", i.ghIssue.GetTitle())
 	str := ""
-	idSp := strings.Repeat(" ", idField-utf8.RuneCountInString(fmt.Sprintf("%v#%v", i.repository.GetName(), i.ghIssue.GetNumber())))
-	asigneeSp := strings.Repeat(" ", asigneeField-utf8.RuneCountInString(fmt.Sprintf("@%v", i.ghIssue.GetAssignee().GetLogin())))
+	idSp := strings.Repeat(" ", layout.id-utf8.RuneCountInString(i.ref()))
+	plainAssignees, coloredAssignees := assigneesStr(i)
 	flexSp := ""
 	ellipsedTitle := ""
 	omitLabels := false
-	remain := maxSize - idField - asigneeField
+	remain := layout.maxSize - layout.id - layout.assignees - layout.fixedWidth() - utf8.RuneCountInString(plainMarkers)
+	// narrow consoles can't fit the id and assignees, the title gives way
+	remain = max(remain, 0)
 	if remain > (i.lenLabelString() + 1 + utf8.RuneCountInString(i.ghIssue.GetTitle())) {
 		flexSp = strings.Repeat(" ", remain-(1+i.lenLabelString()+utf8.RuneCountInString(i.ghIssue.GetTitle())))
 		ellipsedTitle = i.ghIssue.GetTitle()
@@ -54,51 +196,129 @@ func fancyIssueStr(i *issue, idField, asigneeField, maxSize int) string {
 	} else {
 		ellipsedTitle = ellipseStr(i.ghIssue.GetTitle(), remain-i.lenLabelString()-1)
 	}
-	str = "  " + singleColorHub.stableColorize(i.repository.GetName()) + "#" + strconv.Itoa(i.ghIssue.GetNumber()) + idSp + ellipsedTitle + flexSp
+	if i.ghIssue.GetState() == "closed" {
+		ellipsedTitle = singleColorHub.closedColorize(ellipsedTitle)
+	}
+	str = "  " + singleColorHub.repoColorize(i.repository.GetName()) + "#" + strconv.Itoa(i.ghIssue.GetNumber()) + idSp + markers + ellipsedTitle + flexSp
 	if !omitLabels {
-		labels := i.labelNames()
-		coloredLabels := make([]string, 0, len(labels))
-		for _, label := range labels {
-			coloredLabels = append(coloredLabels, singleColorHub.stableColorize(label))
+		coloredLabels := make([]string, 0, len(i.ghIssue.Labels))
+		for _, label := range i.ghIssue.Labels {
+			coloredLabels = append(coloredLabels, singleColorHub.labelColorize(label))
 		}
 		str = str + " " + strings.Join(coloredLabels, ",")
+	} else {
+		str = str + " "
+	}
+	if layout.linked > 0 {
+		linked := linkedStr(i)
+		str = str + " " + padLeft(singleColorHub.styled(singleColorHub.theme.Linked, linked), utf8.RuneCountInString(linked), layout.linked)
 	}
-	if i.ghIssue.GetAssignee().GetLogin() != "" {
-		str = str + " " + asigneeSp + singleColorHub.stableColorize("@"+i.ghIssue.GetAssignee().GetLogin())
+	if layout.milestone > 0 {
+		milestone := milestoneStr(i)
+		str = str + " " + padLeft(singleColorHub.styled(singleColorHub.theme.Milestone, milestone), utf8.RuneCountInString(milestone), layout.milestone)
+	}
+	if layout.comments > 0 {
+		comments := commentsStr(i)
+		str = str + " " + padLeft(comments, utf8.RuneCountInString(comments), layout.comments)
+	}
+	if plainAssignees != "" {
+		str = str + " " + padLeft(coloredAssignees, utf8.RuneCountInString(plainAssignees), layout.assignees-1)
 	}
 	return str
 }
 
-func fancyList(p *ProjectProxy, filter [][]string) {
-	maxID := 0
-	maxAsignee := 0
-	cmax := consoleWidth()
-	for _, col := range p.columns {
-		for _, card := range col.cards {
-			if card.match(filter) {
-				i, isIssue := card.(*issue)
-				if isIssue {
-					idLen := utf8.RuneCountInString(fmt.Sprintf("%v#%v", i.repository.GetName(), i.ghIssue.GetNumber()))
-					maxID = max(maxID, idLen)
-					asLen := utf8.RuneCountInString(i.ghIssue.GetAssignee().GetLogin())
-					maxAsignee = max(maxAsignee, asLen)
-				}
+// fancyCardStr formats any card for the list
+func fancyCardStr(c card, layout *listLayout) string {
+	switch v := c.(type) {
+	case *issue:
+		return fancyIssueStr(v, "", "", layout)
+	case *pullRequest:
+		return fancyPullRequestStr(v, layout)
+	case *note:
+		return fancyNoteStr(v, layout.id, layout.maxSize)
+	}
+	return fmt.Sprintf("no case match for %#v", c)
+}
+
+// changeMarkers flag the cards changed since the last refresh in watch mode
+var changeMarkers = map[string]string{
+	changeAdded:      "+",
+	changeMoved:      "»",
+	changeClosed:     "✕",
+	changeReopened:   "↺",
+	changeReassigned: "@",
+	changeRelabeled:  "#",
+	changeReordered:  "↕",
+}
+
+func changeMarker(kind string) string {
+	style := singleColorHub.theme.Warn
+	switch kind {
+	case changeAdded:
+		style = singleColorHub.theme.Ok
+	case changeClosed:
+		style = singleColorHub.theme.Fail
+	}
+	return singleColorHub.styled(style, changeMarkers[kind])
+}
+
+// sectionHeader is the section name with its card count, columns with a wip
+// limit show the work in them against the limit and get the fail style when
+// over it
+func sectionHeader(section listSection) string {
+	col := section.column
+	if col == nil || col.limit == 0 {
+		return fmt.Sprintf("%v (%v)", singleColorHub.headerColorize(section.name), len(section.entries))
+	}
+	wip := fmt.Sprintf("%v/%v", col.wip(), col.limit)
+	if len(section.entries) != len(col.cards) {
+		wip += fmt.Sprintf(", %v shown", len(section.entries))
+	}
+	if col.overLimit() {
+		return singleColorHub.styled(singleColorHub.theme.Fail, fmt.Sprintf("%v (%v)", section.name, wip))
+	}
+	return fmt.Sprintf("%v (%v)", singleColorHub.headerColorize(section.name), wip)
+}
+
+func fancyList(p *ProjectProxy, filter [][]string, opts *listOptions) {
+	layout := new(listLayout)
+	layout.maxSize = consoleWidth() - 3
+	sections := buildSections(p, filter, opts)
+	for _, section := range sections {
+		for _, entry := range section.entries {
+			i := cardIssue(entry.card)
+			if i != nil {
+				layout.id = max(layout.id, utf8.RuneCountInString(i.ref())+1)
+				plainAssignees, _ := assigneesStr(i)
+				layout.assignees = max(layout.assignees, utf8.RuneCountInString(plainAssignees)+1)
+				layout.linked = max(layout.linked, utf8.RuneCountInString(linkedStr(i)))
+				layout.milestone = max(layout.milestone, utf8.RuneCountInString(milestoneStr(i)))
+				layout.comments = max(layout.comments, utf8.RuneCountInString(commentsStr(i)))
 			}
 		}
 	}
-	for _, col := range p.columns {
-		fmt.Printf("\n%v:\n", col.name)
-		for _, card := range col.cards {
-			if card.match(filter) {
-				switch v := card.(type) {
-				case *issue:
-					fmt.Println(fancyIssueStr(v, maxID+1, maxAsignee+2, cmax-3))
-				case *note:
-					fmt.Println(fancyNoteStr(v, maxID+1, cmax-3))
-				default:
-					fmt.Printf("no case match for %#v", v)
+	layout.id = max(layout.id, utf8.RuneCountInString("note:")+1)
+	layout.fit()
+	lanes := opts.groupBy != "" && opts.groupBy != "column"
+	for _, section := range sections {
+		fmt.Printf("\n%v:\n", sectionHeader(section))
+		column := ""
+		for n, entry := range section.entries {
+			if lanes && entry.column != column {
+				column = entry.column
+				count := 0
+				for _, other := range section.entries[n:] {
+					if other.column == column {
+						count++
+					}
 				}
+				fmt.Printf(" %v (%v)\n", singleColorHub.styled(singleColorHub.theme.Muted, column), count)
+			}
+			line := fancyCardStr(entry.card, layout)
+			if kind, changed := opts.highlight[entry.card.getURL()]; changed {
+				line = changeMarker(kind) + line[1:]
 			}
+			fmt.Println(line)
 		}
 	}
 }
@@ -1,11 +1,15 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
 )
 
 type filterFlags []string
@@ -21,136 +25,240 @@ func (f *filterFlags) String() string {
 	return str
 }
 
-func (f *filterFlags) toFilters() [][]string {
+// toFilters splits -filter values in OR groups of AND terms. Terms are comma
+// separated, whitespace also separates terms when all of them are qualifiers
+// like "state:open type:pr". "@me" is replaced by the configured user
+func (f *filterFlags) toFilters(me string) [][]string {
 	filters := [][]string{}
 	for _, filter := range *f {
-		filters = append(filters, strings.Split(filter, ","))
+		terms := []string{}
+		for _, term := range strings.Split(filter, ",") {
+			for _, subTerm := range splitQualifiers(term) {
+				terms = append(terms, resolveMe(subTerm, me))
+			}
+		}
+		filters = append(filters, terms)
 	}
 	return filters
 }
 
-// TODO: Show help
-func doHelp() {
-	log.Fatal("Unimplemented")
+func splitQualifiers(term string) []string {
+	words := strings.Fields(term)
+	if len(words) < 2 {
+		return []string{term}
+	}
+	for _, word := range words {
+		if !strings.Contains(word, ":") {
+			return []string{term}
+		}
+	}
+	return words
 }
 
-// Checks config or exit with error and help text.
-func checkAllConfig(config *ghpConfig, client *ghpClient) {
+var meRegexp = regexp.MustCompile(`@me\b`)
+
+// resolveMe replaces "@me" by the user login, "assignee:@login" is the same
+// as "assignee:login"
+func resolveMe(term, me string) string {
+	if me != "" {
+		term = meRegexp.ReplaceAllString(term, "@"+me)
+	}
+	return strings.ReplaceAll(term, "assignee:@", "assignee:")
+}
+
+// columnFlags is a repeatable flag of comma separated column names
+type columnFlags []string
+
+func (c *columnFlags) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			*c = append(*c, name)
+		}
+	}
+	return nil
+}
+
+func (c *columnFlags) String() string {
+	return strings.Join(*c, ",")
+}
+
+// Checks config, the error explains how to fix it
+func checkAllConfig(config *ghpConfig, client *ghpClient) error {
 	valid, err := client.validToken()
 	if err != nil {
-		fmt.Printf("There was a problem with the current stored token: %v", err)
+		return fmt.Errorf("there was a problem with the current stored token: %w", err)
 	}
 	if !valid {
-		fmt.Println("ghp is not configured yet, run 'ghp auth' and 'ghp config'")
-		os.Exit(1)
+		return newError(errAuth, "ghp is not configured yet or the token is not valid, run 'ghp auth' and 'ghp config'")
 	}
 	if config.DefaultProjectID == 0 {
-		fmt.Println("You don't have configured ghp yet, run 'ghp config'")
-		os.Exit(1)
+		return newError(errInvalidInput, "you don't have configured ghp yet, run 'ghp config'")
 	}
+	return nil
 }
 
-func doList(state ghpConfig, cache *appCache, client *ghpClient, f filterFlags) {
-	fmt.Printf("Requesting full project %v, this can take some time\n", state.DefaultProject)
+func doAuth(state *ghpConfig, client *ghpClient) error {
+	valid, _ := client.validToken()
+	if valid {
+		if !askForConfirmation("There's already valid token are you sure") {
+			return nil
+		}
+	}
+	userCode, oauthURI, err := client.prepareDeviceForOauth()
+	if err != nil {
+		return fmt.Errorf("error performing oauth: %w", err)
+	}
+	fmt.Println("A browser window will open")
+	fmt.Println("Please insert this code to authorize this client")
+	fmt.Println(userCode)
+	err = openBrowser(oauthURI)
+	if err != nil {
+		fmt.Printf("Couldn't open a browser, please visit %v\n", oauthURI)
+	}
+	fmt.Println("Press enter when done!")
+	fmt.Scanln() // wait for Enter Key
+	err = client.performOauth()
+	if err != nil {
+		return fmt.Errorf("error performing oauth: %w", err)
+	}
+	state.AccessToken = client.getToken()
+	valid, err = client.validToken()
+	if err != nil {
+		return fmt.Errorf("error with token: %w", err)
+	}
+	if !valid {
+		return newError(errAuth, "the new token is not valid")
+	}
+	err = state.save()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Auth changes will clear options, please run 'ghp config'\n")
+	return nil
+}
+
+func doConfig(state *ghpConfig, client *ghpClient) error {
+	valid, err := client.validToken()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return newError(errAuth, "there's no valid oauth token, please run 'ghp auth'")
+	}
+	err = renewConfig(state, client)
+	if err != nil {
+		return err
+	}
+	return state.save()
+}
+
+const interruptGrace = 2 * time.Second
+
+// commandContext returns a context cancelled by the first SIGINT or after
+// timeout when it's not zero, a second SIGINT kills ghp as usual
+func commandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancelAll := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelAll()
+		}
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			signal.Stop(interrupt)
+			cancel()
+			// commands blocked outside the api, like prompts, don't see the
+			// cancellation, give the others some time to clean up
+			time.AfterFunc(interruptGrace, func() {
+				fmt.Fprintln(os.Stderr, "\ninterrupted")
+				os.Exit(130)
+			})
+		case <-ctx.Done():
+			signal.Stop(interrupt)
+		}
+	}()
+	return ctx, cancel
+}
+
+// loadProject reads the default project columns selected by opts and
+// records them in the board index
+func loadProject(state ghpConfig, cache *appCache, client *ghpClient, opts *listOptions) (*ProjectProxy, error) {
 	p := new(ProjectProxy)
 	err := p.init(state, cache, client, state.DefaultProjectID)
 	if err != nil {
-		fmt.Printf("Error creating client %v", err)
+		return nil, fmt.Errorf("error creating client: %w", err)
 	}
-	err = p.pullColums(state.DefaultProjectID)
+	p.progress = newProgress(cache, opts.quiet)
+	p.linked = opts.linked
+	err = p.pullColums(state.DefaultProjectID, opts.showColumn)
 	if err != nil {
-		fmt.Printf("Error reading project %v", err)
+		return nil, fmt.Errorf("error reading project %v: %w", state.DefaultProject, err)
+	}
+	for n := range p.columns {
+		p.columns[n].limit = wipLimit(state, p.columns[n].name)
+	}
+	cache.indexProject(p, state.DefaultProjectID)
+	err = cache.save()
+	if err != nil {
+		verbosef("%v", err)
+	}
+	return p, nil
+}
+
+func doList(state ghpConfig, cache *appCache, client *ghpClient, f filterFlags, opts *listOptions) error {
+	if !opts.quiet {
+		fmt.Printf("Requesting full project %v, this can take some time\n", state.DefaultProject)
+	}
+	p, err := loadProject(state, cache, client, opts)
+	if err != nil {
+		return err
 	}
-	// log.Printf("prj %+v", p)
 	if len(f) != 0 {
 		fmt.Printf("Appliying filters: %v\n", f.String())
 	}
-	//p.listProject(f.toFilters())
-	fancyList(p, f.toFilters())
+	fancyList(p, f.toFilters(state.User), opts)
+	if opts.quiet {
+		return nil
+	}
 	fmt.Printf("\ncache performance:\nHits: %v\nMiss:%v\n", cache.cacheHits, cache.cacheMiss)
+	limit, remaining, reset := client.rateLimit.quota()
+	if limit >= 0 {
+		fmt.Printf("API quota: %v/%v until %v\n", remaining, limit, reset.Format("15:04:05"))
+	}
+	return nil
 }
 
-func main() {
-	state, err := stateLoad()
+func doRateLimit(client *ghpClient) error {
+	limits, err := client.getRateLimits()
 	if err != nil {
-		fmt.Printf("Empty state: %v\n", err)
+		return err
 	}
-	cache := initCache()
-	client := createClient(state.AccessToken)
-	singleColorHub.init()
-
-	// parse flags
-	var filters filterFlags
-	flag.Var(&filters, "filter", "Issue filtering, use a comma separated for AND filter and several -filter paramenters for OR filter")
-	flag.Parse()
-
-	if len(flag.Args()) < 2 {
-		checkAllConfig(state, client)
-		doList(*state, cache, client, filters)
-		os.Exit(0)
+	for _, limit := range []struct {
+		name string
+		rate *github.Rate
+	}{{"core", limits.GetCore()}, {"search", limits.GetSearch()}} {
+		if limit.rate == nil {
+			continue
+		}
+		reset := limit.rate.Reset.Time
+		fmt.Printf("%-7v %5v/%-5v used %-5v resets at %v (in %v)\n", limit.name+":",
+			limit.rate.Remaining, limit.rate.Limit, limit.rate.Limit-limit.rate.Remaining,
+			reset.Format("15:04:05"), time.Until(reset).Round(time.Second))
 	}
+	return nil
+}
 
-	command := flag.Arg(1)
-
-	switch command {
-	case "auth":
-		valid, _ := client.validToken()
-		if valid {
-			if !askForConfirmation("There's already valid token are you sure") {
-				os.Exit(0)
-			}
-		}
-		userCode, oauthURI, err := client.prepareDeviceForOauth()
-		if err != nil {
-			fmt.Printf("Error Performing oauth: %v", err)
-			os.Exit(1)
-		}
-		fmt.Println("A browser window will open")
-		fmt.Println("Please insert this code to authorize this client")
-		fmt.Println(userCode)
-		openBrowser(oauthURI)
-		fmt.Println("Press enter when done!")
-		fmt.Scanln() // wait for Enter Key
-		err = client.performOauth()
-		if err != nil {
-			fmt.Printf("Error Performing oauth: %v", err)
-			os.Exit(1)
-		}
-		state.AccessToken = client.getToken()
-		valid, err = client.validToken()
-		if err != nil {
-			fmt.Printf("Error whith token: %v", err)
-			os.Exit(1)
-		}
-		if valid {
-			err := state.save()
-			if err != nil {
-				fmt.Printf("Error saving config: %v", err)
-				os.Exit(1)
-			}
-			fmt.Printf("Auth changes will clear options, please run 'ghp config'\n")
-		}
-	case "config":
-		valid, _ := client.validToken()
-		if !valid {
-			fmt.Printf("There's no valid oauth token, please run 'ghp auth'")
-		}
-		err = renewConfig(state)
-		if err != nil {
-			fmt.Printf("%v", err)
-			os.Exit(0)
-		}
-		err := state.save()
-		if err != nil {
-			fmt.Printf("Error saving state: %v", err)
-		}
-	case "help":
-		doHelp()
-	case "list":
-		checkAllConfig(state, client)
-		doList(*state, cache, client, filters)
-	default:
-		fmt.Printf("Unsupported command %v\n\n", command)
-		doHelp()
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		fail(err)
 	}
 }
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// lint severities from lowest to highest, "off" disables a rule
+var lintSeverities = []string{"info", "warning", "error"}
+
+// lint formats for --format, github prints workflow annotations
+var lintFormats = []string{"text", "json", "github"}
+
+// issueURLNote matches notes made only of an issue or pull request url of
+// the web host of the api, github.com or the host of an enterprise server
+func issueURLNote(apiURL string) *regexp.Regexp {
+	host := "github.com"
+	if u, err := url.Parse(apiURL); err == nil && u.Host != "" && u.Host != "api.github.com" {
+		host = u.Host
+	}
+	return regexp.MustCompile(`^https?://` + regexp.QuoteMeta(host) + `/([^/\s]+)/([^/\s]+)/(?:issues|pull)/(\d+)/?$`)
+}
+
+// lintFinding is a problem found by a rule, cards with a fix can be repaired
+// with -fix
+type lintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Column   string `json:"column"`
+	Card     string `json:"card"`
+	URL      string `json:"url,omitempty"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+	Fixed    bool   `json:"fixed"`
+	FixError string `json:"fix_error,omitempty"`
+	fix      func(client *ghpClient) error
+}
+
+// lintBoard is the project the rules check
+type lintBoard struct {
+	p      *ProjectProxy
+	stages flowStages
+	apiURL string
+}
+
+type lintRule struct {
+	name        string
+	severity    string
+	description string
+	check       func(b *lintBoard) []lintFinding
+}
+
+// lintRules are the rules with their default severity, config can change it
+// in "lint"
+var lintRules = []lintRule{
+	{"closed-not-done", "error", "closed issues and pull requests outside the done column", lintClosedNotDone},
+	{"open-in-done", "warning", "open issues and pull requests in the done column", lintOpenInDone},
+	{"unassigned-in-progress", "warning", "cards being worked on without assignee", lintUnassigned},
+	{"duplicate-card", "error", "issues with more than one card", lintDuplicates},
+	{"url-note", "warning", "notes with just an issue url instead of the issue card", lintURLNotes},
+	{"no-labels", "info", "open issues without labels", lintNoLabels},
+	{"pr-column", "warning", "pull requests in another column than their linked issue", lintPullColumns},
+}
+
+// lintRuleNamed returns the rule called name
+func lintRuleNamed(name string) lintRule {
+	for _, rule := range lintRules {
+		if rule.name == name {
+			return rule
+		}
+	}
+	return lintRule{name: name}
+}
+
+func newFinding(col *column, c card, message string) lintFinding {
+	f := lintFinding{Column: col.name, Card: cardName(c), Message: message}
+	if i := cardIssue(c); i != nil {
+		f.URL = i.ghIssue.GetHTMLURL()
+	}
+	return f
+}
+
+// column returns a board column by name
+func (b *lintBoard) column(name string) *column {
+	for n := range b.p.columns {
+		if b.p.columns[n].name == name {
+			return &b.p.columns[n]
+		}
+	}
+	return nil
+}
+
+// moveFix moves a card to the top of a column
+func moveFix(c card, to *column) func(client *ghpClient) error {
+	return func(client *ghpClient) error {
+		id, err := cardID(c)
+		if err != nil {
+			return err
+		}
+		return client.moveCard(id, "top", to.id)
+	}
+}
+
+// deleteFix removes a card from the project, the issue is kept
+func deleteFix(c card) func(client *ghpClient) error {
+	return func(client *ghpClient) error {
+		id, err := cardID(c)
+		if err != nil {
+			return err
+		}
+		return client.deleteCard(id)
+	}
+}
+
+func lintClosedNotDone(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	done := b.column(b.stages.done)
+	for n := range b.p.columns {
+		col := &b.p.columns[n]
+		if col == done {
+			continue
+		}
+		for _, c := range col.cards {
+			if i := cardIssue(c); i != nil && i.ghIssue.GetState() == "closed" {
+				f := newFinding(col, c, "closed but not in "+done.name)
+				f.Fix, f.fix = "move to "+done.name, moveFix(c, done)
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+func lintOpenInDone(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	done := b.column(b.stages.done)
+	for _, c := range done.cards {
+		if i := cardIssue(c); i != nil && i.ghIssue.GetState() == "open" {
+			findings = append(findings, newFinding(done, c, "still open"))
+		}
+	}
+	return findings
+}
+
+// lintUnassigned checks the columns from the start column to the one before
+// done
+func lintUnassigned(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	start, done := b.stages.rank(b.stages.start), b.stages.rank(b.stages.done)
+	for n := range b.p.columns {
+		col := &b.p.columns[n]
+		if rank := b.stages.rank(col.name); rank < start || rank >= done {
+			continue
+		}
+		for _, c := range col.cards {
+			if i := cardIssue(c); i != nil && len(i.assigneeLogins()) == 0 {
+				findings = append(findings, newFinding(col, c, "nobody assigned"))
+			}
+		}
+	}
+	return findings
+}
+
+func lintDuplicates(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	first := make(map[string]string)
+	for n := range b.p.columns {
+		col := &b.p.columns[n]
+		for _, c := range col.cards {
+			i := cardIssue(c)
+			if i == nil {
+				continue
+			}
+			url := i.ghIssue.GetURL()
+			if other, seen := first[url]; seen {
+				f := newFinding(col, c, "also has a card in "+other)
+				f.Fix, f.fix = "delete this card", deleteFix(c)
+				findings = append(findings, f)
+				continue
+			}
+			first[url] = col.name
+		}
+	}
+	return findings
+}
+
+// lintURLNotes replaces url notes with the issue card at the same place, or
+// deletes them when the issue already has a card. Only the first note of an
+// issue is replaced, the others are deleted once its card is added
+func lintURLNotes(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	urlNote := issueURLNote(b.apiURL)
+	replaced := make(map[string]*lintNoteReplace)
+	cards := make(map[string]string)
+	for _, col := range b.p.columns {
+		for _, c := range col.cards {
+			if i := cardIssue(c); i != nil {
+				cards[i.ghIssue.GetURL()] = col.name
+			}
+		}
+	}
+	for n := range b.p.columns {
+		col := &b.p.columns[n]
+		for _, c := range col.cards {
+			cardNote, isNote := c.(*note)
+			if !isNote {
+				continue
+			}
+			match := urlNote.FindStringSubmatch(strings.TrimSpace(cardNote.text))
+			if match == nil {
+				continue
+			}
+			url := fmt.Sprintf("%vrepos/%v/%v/issues/%v", b.apiURL, match[1], match[2], match[3])
+			f := newFinding(col, c, "just links "+match[2]+"#"+match[3])
+			if other, exists := cards[url]; exists {
+				f.Message += ", which has a card in " + other
+				f.Fix, f.fix = "delete the note", deleteFix(c)
+			} else if first, planned := replaced[url]; planned {
+				f.Message += ", like a note in " + first.column
+				f.Fix, f.fix = "delete the note", first.deleteFix(c)
+			} else {
+				replace := &lintNoteReplace{column: col.name}
+				replaced[url] = replace
+				f.Fix, f.fix = "replace with the issue card", replace.fix(c, col, url)
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// lintNoteReplace is the replacement of the first url note of an issue, the
+// other notes of the issue wait for it
+type lintNoteReplace struct {
+	column string
+	added  bool
+}
+
+// fix adds the issue card after the note and deletes the note
+func (r *lintNoteReplace) fix(c card, col *column, url string) func(client *ghpClient) error {
+	replace := replaceNoteFix(c, col, url)
+	return func(client *ghpClient) error {
+		err := replace(client)
+		r.added = err == nil
+		return err
+	}
+}
+
+// deleteFix deletes another note of the issue once its card is added
+func (r *lintNoteReplace) deleteFix(c card) func(client *ghpClient) error {
+	remove := deleteFix(c)
+	return func(client *ghpClient) error {
+		if !r.added {
+			return fmt.Errorf("kept, the issue card wasn't added in %v", r.column)
+		}
+		return remove(client)
+	}
+}
+
+// replaceNoteFix adds the issue card after the note and deletes the note
+func replaceNoteFix(c card, col *column, url string) func(client *ghpClient) error {
+	return func(client *ghpClient) error {
+		noteID, err := cardID(c)
+		if err != nil {
+			return err
+		}
+		content, err := cardContent(client, url)
+		if err != nil {
+			return err
+		}
+		added, err := client.createCard(col.id, content)
+		if err != nil {
+			return err
+		}
+		err = client.moveCard(added.GetID(), fmt.Sprintf("after:%v", noteID), col.id)
+		if err != nil {
+			return err
+		}
+		return client.deleteCard(noteID)
+	}
+}
+
+func lintNoLabels(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	for n := range b.p.columns {
+		col := &b.p.columns[n]
+		for _, c := range col.cards {
+			i, isIssue := c.(*issue)
+			if isIssue && i.ghIssue.GetState() == "open" && len(i.ghIssue.Labels) == 0 {
+				findings = append(findings, newFinding(col, c, "no labels"))
+			}
+		}
+	}
+	return findings
+}
+
+// lintPullColumns compares the column of issues with the one of the pull
+// requests linked to them
+func lintPullColumns(b *lintBoard) []lintFinding {
+	findings := []lintFinding{}
+	type pullCard struct {
+		card   card
+		column *column
+	}
+	pulls := make(map[string]pullCard)
+	for n := range b.p.columns {
+		for _, c := range b.p.columns[n].cards {
+			if pr, isPull := c.(*pullRequest); isPull {
+				pulls[pr.ref()] = pullCard{c, &b.p.columns[n]}
+			}
+		}
+	}
+	for _, col := range b.p.columns {
+		for _, c := range col.cards {
+			i, isIssue := c.(*issue)
+			if !isIssue {
+				continue
+			}
+			for _, ref := range i.linkedPulls {
+				if strings.HasPrefix(ref, "#") {
+					ref = i.repository.GetName() + ref
+				}
+				pull, exists := pulls[ref]
+				if exists && pull.column.name != col.name {
+					findings = append(findings, newFinding(pull.column, pull.card,
+						fmt.Sprintf("its issue %v is in %v", i.ref(), col.name)))
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// lintSeverity returns the configured severity of a rule
+func lintSeverity(state ghpConfig, rule lintRule) (string, error) {
+	severity, exists := state.Lint[rule.name]
+	if !exists {
+		return rule.severity, nil
+	}
+	if severity != "off" && !contains(lintSeverities, severity) {
+		return "", newError(errInvalidInput, "invalid severity %v for lint rule %v, use off, %v", severity, rule.name,
+			strings.Join(lintSeverities, ", "))
+	}
+	return severity, nil
+}
+
+// severityRank orders severities, "none" is above all of them
+func severityRank(severity string) int {
+	for n, s := range lintSeverities {
+		if s == severity {
+			return n
+		}
+	}
+	return len(lintSeverities)
+}
+
+// runLint checks the board with the enabled rules, most severe first
+func runLint(state ghpConfig, b *lintBoard) ([]lintFinding, error) {
+	findings := []lintFinding{}
+	for rank := len(lintSeverities) - 1; rank >= 0; rank-- {
+		for _, rule := range lintRules {
+			severity, err := lintSeverity(state, rule)
+			if err != nil {
+				return nil, err
+			}
+			if severityRank(severity) != rank {
+				continue
+			}
+			for _, f := range rule.check(b) {
+				f.Rule, f.Severity = rule.name, severity
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings, nil
+}
+
+func writeLintText(w io.Writer, findings []lintFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No problems found")
+		return
+	}
+	styles := map[string]string{"error": singleColorHub.theme.Fail, "warning": singleColorHub.theme.Warn,
+		"info": singleColorHub.theme.Muted}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	counts := make(map[string]int)
+	fixable, failed := 0, 0
+	for _, f := range findings {
+		fix := ""
+		switch {
+		case f.Fixed:
+			fix = "fixed: " + f.Fix
+		case f.FixError != "":
+			fix = "fix failed: " + f.FixError
+			failed++
+		case f.Fix != "":
+			fix = "fix: " + f.Fix
+			fixable++
+		}
+		if !f.Fixed {
+			counts[f.Severity]++
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", singleColorHub.styled(styles[f.Severity], f.Severity), f.Rule, f.Column,
+			f.Card, f.Message, singleColorHub.styled(singleColorHub.theme.Muted, fix))
+	}
+	tw.Flush()
+	summary := []string{}
+	for rank := len(lintSeverities) - 1; rank >= 0; rank-- {
+		if count := counts[lintSeverities[rank]]; count != 0 {
+			summary = append(summary, fmt.Sprintf("%v %v", count, lintSeverities[rank]))
+		}
+	}
+	if fixable != 0 {
+		summary = append(summary, fmt.Sprintf("%v fixable with -fix", fixable))
+	}
+	if failed != 0 {
+		summary = append(summary, fmt.Sprintf("%v fixes failed", failed))
+	}
+	if len(summary) != 0 {
+		fmt.Fprintf(w, "\n%v\n", strings.Join(summary, ", "))
+	}
+}
+
+// writeLintGithub prints github workflow annotations
+func writeLintGithub(w io.Writer, findings []lintFinding) {
+	levels := map[string]string{"error": "error", "warning": "warning", "info": "notice"}
+	for _, f := range findings {
+		if f.Fixed {
+			continue
+		}
+		fmt.Fprintf(w, "::%v title=ghp lint %v::%v in %v: %v\n", levels[f.Severity], f.Rule, f.Card, f.Column, f.Message)
+	}
+}
+
+// lintOptions are the lint flags
+type lintOptions struct {
+	fix    bool
+	yes    bool
+	format string
+	failOn string
+	rules  bool
+}
+
+func printLintRules(w io.Writer, state ghpConfig) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, rule := range lintRules {
+		severity, err := lintSeverity(state, rule)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", rule.name, severity, rule.description)
+	}
+	return tw.Flush()
+}
+
+// confirmLintFixes lists the fixes and asks before making them, machine
+// readable formats need -yes
+func confirmLintFixes(findings []lintFinding, opts *lintOptions) error {
+	fixes, deletes := 0, 0
+	for _, f := range findings {
+		if f.fix != nil {
+			fixes++
+			if strings.HasPrefix(f.Fix, "delete") {
+				deletes++
+			}
+		}
+	}
+	if fixes == 0 || opts.yes {
+		return nil
+	}
+	if opts.format != "text" {
+		return newError(errInvalidInput, "-fix with -format %v needs -yes", opts.format)
+	}
+	for _, f := range findings {
+		if f.fix != nil {
+			fmt.Printf("  %v in %v: %v\n", f.Card, f.Column, f.Fix)
+		}
+	}
+	question := fmt.Sprintf("Fix %v problems", fixes)
+	if deletes != 0 {
+		question += fmt.Sprintf(", deleting %v cards", deletes)
+	}
+	if !askForConfirmation(question) {
+		return newError(errInterrupted, "aborted, nothing changed")
+	}
+	fmt.Println()
+	return nil
+}
+
+// applyLintFixes runs the fixes in order, a failed fix doesn't stop the
+// others. Returns how many failed
+func applyLintFixes(client *ghpClient, findings []lintFinding) int {
+	failed := 0
+	for n := range findings {
+		if findings[n].fix == nil {
+			continue
+		}
+		err := findings[n].fix(client)
+		if err != nil {
+			findings[n].FixError = err.Error()
+			failed++
+			continue
+		}
+		findings[n].Fixed = true
+	}
+	return failed
+}
+
+func doLint(env *cmdEnv, opts *lintOptions) error {
+	state := *env.state
+	if opts.rules {
+		return printLintRules(os.Stdout, state)
+	}
+	if !contains(lintFormats, opts.format) {
+		return newError(errInvalidInput, "invalid format %v, use one of %v", opts.format, strings.Join(lintFormats, ", "))
+	}
+	if opts.failOn != "none" && !contains(lintSeverities, opts.failOn) {
+		return newError(errInvalidInput, "invalid -fail-on %v, use none, %v", opts.failOn, strings.Join(lintSeverities, ", "))
+	}
+	err := checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	// pr-column needs the pull requests linked to every issue
+	severity, err := lintSeverity(state, lintRuleNamed("pr-column"))
+	if err != nil {
+		return err
+	}
+	env.opts.linked = severity != "off"
+	p, err := loadProject(state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	columns := []string{}
+	for _, col := range p.columns {
+		columns = append(columns, col.name)
+	}
+	stages, err := newFlowStages(columns, state.CycleStartColumn, state.CycleDoneColumn)
+	if err != nil {
+		return err
+	}
+	b := &lintBoard{p: p, stages: stages, apiURL: env.client.apiClient.BaseURL.String()}
+	findings, err := runLint(state, b)
+	if err != nil {
+		return err
+	}
+
+	failedFixes := 0
+	if opts.fix {
+		err = confirmLintFixes(findings, opts)
+		if err != nil {
+			return err
+		}
+		failedFixes = applyLintFixes(env.client, findings)
+	}
+
+	switch opts.format {
+	case "json":
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "github":
+		writeLintGithub(os.Stdout, findings)
+	default:
+		writeLintText(os.Stdout, findings)
+	}
+
+	if failedFixes != 0 {
+		return fmt.Errorf("%v fixes failed", failedFixes)
+	}
+	failing := 0
+	for _, f := range findings {
+		if !f.Fixed && severityRank(f.Severity) >= severityRank(opts.failOn) {
+			failing++
+		}
+	}
+	if failing != 0 {
+		return newError(errCheckFailed, "%v problems at or above %v", failing, opts.failOn)
+	}
+	return nil
+}
+
+func init() {
+	opts := &lintOptions{}
+	registerCommand(&ghpCommand{
+		name:    "lint",
+		summary: "Check the board against hygiene rules",
+		description: `
+Rules look for closed issues out of the done column, open ones in it, work
+without assignee, duplicated cards, notes with just an issue url, issues
+without labels and pull requests away from their issue. Done and in progress
+columns are the cycle columns of 'ghp stats'.
+
+Every rule has a severity, info, warning or error, that can be changed or set
+to off in the "lint" config map, -rules lists them. Exits with code 7 when any
+problem is at or above -fail-on. -fix moves closed issues to done, deletes
+duplicated cards and replaces url notes with the issue card, after listing
+the fixes and asking, -yes skips the question and is needed with -format json
+or github. A failed fix doesn't stop the others, every result is shown.`,
+		examples: []string{
+			"ghp lint",
+			"ghp lint -fix",
+			"ghp lint -fix -yes -format json",
+			"ghp lint -format github -fail-on warning",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			fs.BoolVar(&opts.fix, "fix", false, "Fix the problems that have a fix")
+			fs.BoolVar(&opts.yes, "yes", false, "Don't ask for confirmation before fixing")
+			fs.StringVar(&opts.format, "format", "text", "Output format: "+strings.Join(lintFormats, ", "))
+			fs.StringVar(&opts.failOn, "fail-on", "error", "Lowest severity that fails, or none")
+			fs.BoolVar(&opts.rules, "rules", false, "List the rules and their severity")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help lint'", strings.Join(args, " "))
+			}
+			return doLint(env, opts)
+		},
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// lintIssue is an issue of org/api of the api at apiURL on the card with the
+// given id
+func lintIssue(apiURL string, number int, id int64, state string, labels ...string) *issue {
+	i := testIssue(number, labels...)
+	i.url = fmt.Sprintf("%vprojects/columns/cards/%v", apiURL, id)
+	i.ghIssue.State = github.String(state)
+	i.ghIssue.URL = github.String(fmt.Sprintf("%vrepos/org/api/issues/%v", apiURL, number))
+	return i
+}
+
+func testLintBoard(t *testing.T, apiURL string, columns []column) *lintBoard {
+	names := []string{}
+	for _, col := range columns {
+		names = append(names, col.name)
+	}
+	stages, err := newFlowStages(names, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &lintBoard{p: &ProjectProxy{columns: columns}, stages: stages, apiURL: apiURL}
+}
+
+// findingStrings describes findings as "column card: message (fix)", notes
+// are just "note"
+func findingStrings(findings []lintFinding) []string {
+	strs := []string{}
+	for _, f := range findings {
+		c := f.Card
+		if strings.HasPrefix(c, "note ") {
+			c = "note"
+		}
+		s := fmt.Sprintf("%v %v: %v", f.Column, c, f.Message)
+		if f.Fix != "" {
+			s += " (" + f.Fix + ")"
+		}
+		strs = append(strs, s)
+	}
+	return strs
+}
+
+func TestLintRules(t *testing.T) {
+	const gh = "https://api.github.com/"
+	assigned := lintIssue(gh, 3, 13, "open")
+	assigned.ghIssue.Assignees = []*github.User{{Login: github.String("ann")}}
+	linked := lintIssue(gh, 1, 11, "open")
+	linked.linkedPulls = []string{"#5", "web#6"}
+	webPull := &pullRequest{issue: *lintIssue(gh, 6, 16, "open")}
+	webPull.repository = &github.Repository{Name: github.String("web")}
+
+	tests := []struct {
+		rule    string
+		apiURL  string
+		columns []column
+		want    []string
+	}{
+		{
+			"closed-not-done", gh,
+			[]column{
+				{name: "To do", cards: []card{lintIssue(gh, 1, 11, "closed"), lintIssue(gh, 2, 12, "open")}},
+				{name: "In progress"},
+				{name: "Done", cards: []card{lintIssue(gh, 3, 13, "closed")}},
+			},
+			[]string{"To do api#1: closed but not in Done (move to Done)"},
+		},
+		{
+			"open-in-done", gh,
+			[]column{
+				{name: "To do", cards: []card{lintIssue(gh, 1, 11, "open")}},
+				{name: "In progress"},
+				{name: "Done", cards: []card{lintIssue(gh, 2, 12, "open"), lintIssue(gh, 3, 13, "closed")}},
+			},
+			[]string{"Done api#2: still open"},
+		},
+		{
+			"unassigned-in-progress", gh,
+			[]column{
+				{name: "To do", cards: []card{lintIssue(gh, 1, 11, "open")}},
+				{name: "In progress", cards: []card{lintIssue(gh, 2, 12, "open"), assigned, &note{text: "plan"}}},
+				{name: "Done", cards: []card{lintIssue(gh, 4, 14, "closed")}},
+			},
+			[]string{"In progress api#2: nobody assigned"},
+		},
+		{
+			"duplicate-card", gh,
+			[]column{
+				{name: "To do", cards: []card{lintIssue(gh, 1, 11, "open"), lintIssue(gh, 2, 12, "open")}},
+				{name: "In progress", cards: []card{lintIssue(gh, 1, 21, "open")}},
+				{name: "Done", cards: []card{lintIssue(gh, 1, 31, "open")}},
+			},
+			[]string{
+				"In progress api#1: also has a card in To do (delete this card)",
+				"Done api#1: also has a card in To do (delete this card)",
+			},
+		},
+		{
+			"url-note", gh,
+			[]column{
+				{name: "To do", cards: []card{
+					&note{text: "https://github.com/org/api/pull/5"},
+					&note{text: " https://github.com/org/api/issues/1/\n"},
+					&note{text: "see https://github.com/org/api/issues/6"},
+					&note{text: "https://ghe.example.com/org/api/issues/7"},
+				}},
+				{name: "In progress", cards: []card{&note{text: "http://github.com/org/api/issues/5"}}},
+				{name: "Done", cards: []card{lintIssue(gh, 1, 11, "closed")}},
+			},
+			[]string{
+				"To do note: just links api#5 (replace with the issue card)",
+				"To do note: just links api#1, which has a card in Done (delete the note)",
+				"In progress note: just links api#5, like a note in To do (delete the note)",
+			},
+		},
+		{
+			"url-note", "https://ghe.example.com/api/v3/",
+			[]column{
+				{name: "To do", cards: []card{
+					&note{text: "https://ghe.example.com/org/api/issues/7"},
+					&note{text: "https://github.com/org/api/issues/8"},
+				}},
+				{name: "Done"},
+			},
+			[]string{"To do note: just links api#7 (replace with the issue card)"},
+		},
+		{
+			"no-labels", gh,
+			[]column{
+				{name: "To do", cards: []card{lintIssue(gh, 1, 11, "open"), lintIssue(gh, 2, 12, "open", "bug"),
+					&pullRequest{issue: *lintIssue(gh, 3, 13, "open")}, &note{text: "plan"}}},
+				{name: "Done", cards: []card{lintIssue(gh, 4, 14, "closed")}},
+			},
+			[]string{"To do api#1: no labels"},
+		},
+		{
+			"pr-column", gh,
+			[]column{
+				{name: "To do"},
+				{name: "In progress", cards: []card{linked, webPull}},
+				{name: "Done", cards: []card{&pullRequest{issue: *lintIssue(gh, 5, 15, "closed")}}},
+			},
+			[]string{"Done api#5: its issue api#1 is in In progress"},
+		},
+	}
+	for _, test := range tests {
+		b := testLintBoard(t, test.apiURL, test.columns)
+		got := findingStrings(lintRuleNamed(test.rule).check(b))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %v: got %q, want %q", test.rule, test.apiURL, got, test.want)
+		}
+	}
+}
+
+func TestLintFixes(t *testing.T) {
+	requests := []string{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/org/api/issues/3":
+			fmt.Fprint(w, `{"id": 303, "number": 3}`)
+		case r.Method == "GET":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": 99}`)
+		}
+	}))
+	defer api.Close()
+	client := createClient(context.Background(), "x")
+	client.apiClient.BaseURL, _ = url.Parse(api.URL + "/")
+	apiURL := client.apiClient.BaseURL.String()
+	web := api.URL + "/org/api/"
+	cardNote := func(id int64, text string) *note {
+		return &note{text: text, url: fmt.Sprintf("%vprojects/columns/cards/%v", apiURL, id)}
+	}
+
+	b := testLintBoard(t, apiURL, []column{
+		{name: "To do", id: 1, cards: []card{
+			cardNote(21, web+"issues/3"),
+			lintIssue(apiURL, 1, 11, "open", "bug"),
+			cardNote(22, web+"pull/1"),
+			cardNote(23, web+"issues/4"),
+		}},
+		{name: "In progress", id: 2, cards: []card{
+			cardNote(24, web+"issues/3"),
+			lintIssue(apiURL, 1, 12, "open", "bug"),
+			lintIssue(apiURL, 2, 14, "closed", "bug"),
+			cardNote(25, web+"issues/4"),
+		}},
+		{name: "Done", id: 3},
+	})
+	findings, err := runLint(ghpConfig{Lint: map[string]string{"unassigned-in-progress": "off"}}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := applyLintFixes(client, findings); failed != 2 {
+		t.Errorf("got %v failed fixes, want 2", failed)
+	}
+
+	results := []string{}
+	for _, f := range findings {
+		result := f.FixError
+		if f.Fixed {
+			result = "fixed"
+		}
+		results = append(results, fmt.Sprintf("%v %v: %v", f.Rule, f.Fix, result))
+	}
+	wantResults := []string{
+		"closed-not-done move to Done: fixed",
+		"duplicate-card delete this card: fixed",
+		"url-note replace with the issue card: fixed",
+		"url-note delete the note: fixed",
+		"url-note replace with the issue card: " + findings[4].FixError,
+		"url-note delete the note: fixed",
+		"url-note delete the note: kept, the issue card wasn't added in To do",
+	}
+	if !reflect.DeepEqual(results, wantResults) || findings[4].FixError == "" {
+		t.Errorf("got results %q, want %q", results, wantResults)
+	}
+	wantRequests := []string{
+		"POST /projects/columns/cards/14/moves",
+		"DELETE /projects/columns/cards/12",
+		"GET /repos/org/api/issues/3",
+		"POST /projects/columns/1/cards",
+		"POST /projects/columns/cards/99/moves",
+		"DELETE /projects/columns/cards/21",
+		"DELETE /projects/columns/cards/22",
+		"GET /repos/org/api/issues/4",
+		"DELETE /projects/columns/cards/24",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("got requests %q, want %q", requests, wantRequests)
+	}
+}
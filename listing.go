@@ -0,0 +1,297 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// sort keys for --sort, prefix with "-" to reverse
+var sortKeys = []string{"created", "updated", "repo", "number", "assignee", "title", "label"}
+
+// group keys for --group-by
+var groupKeys = []string{"column", "assignee", "repo", "label", "milestone"}
+
+// default label priority, highest first, used when config has none
+var defaultLabelPriority = []string{"p0", "p1", "p2", "p3", "critical", "high", "medium", "low"}
+
+type listOptions struct {
+	sortBy         string
+	groupBy        string
+	columns        []string
+	excludeColumns []string
+	labelPriority  []string
+	quiet          bool
+	hideEmpty      bool              // leave out the sections without cards
+	linked         bool              // fetch the pull requests linked to issues
+	highlight      map[string]string // change kind by card url, set by watch
+}
+
+// listEntry is a card and the column it belongs to
+type listEntry struct {
+	column string
+	card   card
+}
+
+// listSection is a swimlane of the list, entries keep column order
+type listSection struct {
+	name    string
+	entries []listEntry
+	column  *column // the column of the section when listing by column
+}
+
+func (o *listOptions) validate() error {
+	if o.sortBy != "" && !contains(sortKeys, strings.TrimPrefix(o.sortBy, "-")) {
+		return newError(errInvalidInput, "invalid sort %v, use one of %v", o.sortBy, strings.Join(sortKeys, ", "))
+	}
+	if o.groupBy != "" && !contains(groupKeys, o.groupBy) {
+		return newError(errInvalidInput, "invalid group %v, use one of %v", o.groupBy, strings.Join(groupKeys, ", "))
+	}
+	return nil
+}
+
+// matchColumn checks a column name against names or prefixes, ignoring case
+func matchColumn(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if strings.HasPrefix(name, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// showColumn is true for columns selected with --column and not excluded
+// with --exclude-column
+func (o *listOptions) showColumn(name string) bool {
+	if len(o.columns) != 0 && !matchColumn(name, o.columns) {
+		return false
+	}
+	return !matchColumn(name, o.excludeColumns)
+}
+
+// cardIssue returns the issue behind a card, nil for notes
+func cardIssue(c card) *issue {
+	switch v := c.(type) {
+	case *issue:
+		return v
+	case *pullRequest:
+		return &v.issue
+	}
+	return nil
+}
+
+func cardCreated(c card) time.Time {
+	if i := cardIssue(c); i != nil {
+		return i.ghIssue.GetCreatedAt()
+	}
+	if n, isNote := c.(*note); isNote {
+		return n.createdAt.Time
+	}
+	return time.Time{}
+}
+
+func cardUpdated(c card) time.Time {
+	if i := cardIssue(c); i != nil {
+		return i.ghIssue.GetUpdatedAt()
+	}
+	if n, isNote := c.(*note); isNote {
+		return n.updatedAt.Time
+	}
+	return time.Time{}
+}
+
+func cardTitle(c card) string {
+	if i := cardIssue(c); i != nil {
+		return i.ghIssue.GetTitle()
+	}
+	if n, isNote := c.(*note); isNote {
+		return strings.Split(n.text, "\n")[0]
+	}
+	return ""
+}
+
+// labelRank is the position of the best priority label of the card, cards
+// without priority labels rank after all of them
+func labelRank(c card, priority []string) int {
+	i := cardIssue(c)
+	if i == nil {
+		return len(priority)
+	}
+	rank := len(priority)
+	for _, label := range i.labelNames() {
+		name := strings.ToLower(label)
+		for n, p := range priority {
+			p = strings.ToLower(p)
+			if n < rank && (name == p || strings.HasSuffix(name, ":"+p) || strings.HasSuffix(name, " "+p) || strings.HasSuffix(name, "/"+p)) {
+				rank = n
+			}
+		}
+	}
+	return rank
+}
+
+// lessCards compares two cards by key, notes go after issues for the keys
+// they don't have even when the order is reversed
+func lessCards(a, b card, key string, reverse bool, priority []string) bool {
+	ia, ib := cardIssue(a), cardIssue(b)
+	if reverse {
+		switch key {
+		case "repo", "number", "assignee":
+			if ia == nil || ib == nil {
+				return ia != nil
+			}
+		}
+		a, b = b, a
+		ia, ib = ib, ia
+	}
+	switch key {
+	case "created":
+		return cardCreated(a).Before(cardCreated(b))
+	case "updated":
+		return cardUpdated(a).Before(cardUpdated(b))
+	case "title":
+		return strings.ToLower(cardTitle(a)) < strings.ToLower(cardTitle(b))
+	case "label":
+		return labelRank(a, priority) < labelRank(b, priority)
+	}
+	if ia == nil || ib == nil {
+		return ia != nil
+	}
+	switch key {
+	case "repo":
+		return ia.repository.GetName() < ib.repository.GetName()
+	case "number":
+		return ia.ghIssue.GetNumber() < ib.ghIssue.GetNumber()
+	case "assignee":
+		la, lb := ia.assigneeLogins(), ib.assigneeLogins()
+		if len(la) == 0 || len(lb) == 0 {
+			return len(la) != 0
+		}
+		return la[0] < lb[0]
+	}
+	return false
+}
+
+func sortEntries(entries []listEntry, opts *listOptions) {
+	if opts.sortBy == "" {
+		return
+	}
+	key := strings.TrimPrefix(opts.sortBy, "-")
+	reverse := strings.HasPrefix(opts.sortBy, "-")
+	sort.SliceStable(entries, func(a, b int) bool {
+		return lessCards(entries[a].card, entries[b].card, key, reverse, opts.labelPriority)
+	})
+}
+
+// groupNames returns the sections a card belongs to, a card with several
+// assignees or labels is listed in each one
+func groupNames(entry listEntry, key string) []string {
+	i := cardIssue(entry.card)
+	switch key {
+	case "assignee":
+		if i == nil || len(i.assigneeLogins()) == 0 {
+			return []string{"unassigned"}
+		}
+		names := []string{}
+		for _, login := range i.assigneeLogins() {
+			names = append(names, "@"+login)
+		}
+		return names
+	case "repo":
+		if i == nil {
+			return []string{"notes"}
+		}
+		return []string{i.repository.GetName()}
+	case "label":
+		if i == nil || len(i.labelNames()) == 0 {
+			return []string{"no label"}
+		}
+		return i.labelNames()
+	case "milestone":
+		if i == nil || i.ghIssue.Milestone == nil {
+			return []string{"no milestone"}
+		}
+		return []string{i.ghIssue.GetMilestone().GetTitle()}
+	}
+	return []string{entry.column}
+}
+
+// buildSections filters and sorts the project cards and splits them in
+// sections, one per column unless grouping by something else
+func buildSections(p *ProjectProxy, filter [][]string, opts *listOptions) []listSection {
+	entries := []listEntry{}
+	for _, col := range p.columns {
+		if !opts.showColumn(col.name) {
+			continue
+		}
+		for _, card := range col.cards {
+			if card.match(filter) {
+				entries = append(entries, listEntry{col.name, card})
+			}
+		}
+	}
+	sortEntries(entries, opts)
+
+	if opts.groupBy == "" || opts.groupBy == "column" {
+		sections := []listSection{}
+		for n, col := range p.columns {
+			if !opts.showColumn(col.name) {
+				continue
+			}
+			section := listSection{name: col.name, column: &p.columns[n]}
+			for _, entry := range entries {
+				if entry.column == col.name {
+					section.entries = append(section.entries, entry)
+				}
+			}
+			if opts.hideEmpty && len(section.entries) == 0 {
+				continue
+			}
+			sections = append(sections, section)
+		}
+		return sections
+	}
+
+	byName := make(map[string]*listSection)
+	names := []string{}
+	for _, entry := range entries {
+		for _, name := range groupNames(entry, opts.groupBy) {
+			section, exists := byName[name]
+			if !exists {
+				section = &listSection{name: name}
+				byName[name] = section
+				names = append(names, name)
+			}
+			section.entries = append(section.entries, entry)
+		}
+	}
+	sort.SliceStable(names, func(a, b int) bool {
+		emptyA, emptyB := isEmptyGroup(names[a]), isEmptyGroup(names[b])
+		if emptyA != emptyB {
+			return emptyB
+		}
+		return strings.ToLower(names[a]) < strings.ToLower(names[b])
+	})
+	sections := make([]listSection, 0, len(names))
+	for _, name := range names {
+		section := byName[name]
+		// keep column order inside the lane
+		lane := make([]listEntry, 0, len(section.entries))
+		for _, col := range p.columns {
+			for _, entry := range section.entries {
+				if entry.column == col.name {
+					lane = append(lane, entry)
+				}
+			}
+		}
+		section.entries = lane
+		sections = append(sections, *section)
+	}
+	return sections
+}
+
+// isEmptyGroup is true for the catch all sections listed last
+func isEmptyGroup(name string) bool {
+	return name == "unassigned" || name == "notes" || name == "no label" || name == "no milestone"
+}
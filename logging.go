@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// log levels set by --verbose and --debug
+const (
+	logNormal = iota
+	logVerbose
+	logDebug
+)
+
+var logLevel = logNormal
+
+var logger = log.New(os.Stderr, "", log.Ltime|log.Lmicroseconds)
+
+func verbosef(format string, args ...interface{}) {
+	if logLevel >= logVerbose {
+		logger.Printf(format, args...)
+	}
+}
+
+func debugf(format string, args ...interface{}) {
+	if logLevel >= logDebug {
+		logger.Printf(format, args...)
+	}
+}
+
+// secret query parameters and headers never written to the log
+var secretParams = []string{"access_token", "client_secret", "code", "device_code"}
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// loggingTransport logs a summary of every request with --verbose and its
+// headers with --debug, credentials are redacted
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func newLoggingTransport(base http.RoundTripper) *loggingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &loggingTransport{base: base}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if logLevel < logVerbose {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	debugf("> %v %v", req.Method, redactURL(req.URL))
+	logHeaders(">", req.Header)
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		verbosef("%v %v failed after %v: %v", req.Method, redactURL(req.URL), elapsed, err)
+		return nil, err
+	}
+	verbosef("%v %v %v %v (quota %v)", req.Method, redactURL(req.URL), resp.StatusCode, elapsed,
+		resp.Header.Get("X-RateLimit-Remaining"))
+	logHeaders("<", resp.Header)
+	return resp, nil
+}
+
+func logHeaders(prefix string, headers http.Header) {
+	if logLevel < logDebug {
+		return
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(headers[name], ", ")
+		for _, secret := range secretHeaders {
+			if strings.EqualFold(name, secret) {
+				value = redact(value)
+			}
+		}
+		debugf("%v %v: %v", prefix, name, value)
+	}
+}
+
+// redact keeps the scheme of authorization values, "token ***"
+func redact(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) > 1 {
+		return fields[0] + " ***"
+	}
+	return "***"
+}
+
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	clean := *u
+	query := clean.Query()
+	for _, param := range secretParams {
+		if query.Get(param) != "" {
+			query.Set(param, "***")
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
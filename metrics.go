@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsWriter writes the prometheus text exposition format
+type metricsWriter struct {
+	w       io.Writer
+	project string
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// family starts a metric with its help and type
+func (m *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// sample writes a value, labels are name and value pairs. Board metrics
+// get the project label
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	if m.project != "" {
+		labels = append([]string{"project", m.project}, labels...)
+	}
+	pairs := []string{}
+	for n := 0; n+1 < len(labels); n += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%v="%v"`, labels[n], metricLabelEscaper.Replace(labels[n+1])))
+	}
+	if len(pairs) != 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(m.w, "%v %v\n", name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// columnLabels identify a column, boards can have two columns with the same
+// name
+func columnLabels(col *column, labels ...string) []string {
+	return append([]string{"column", col.name, "column_id", strconv.FormatInt(col.id, 10)}, labels...)
+}
+
+// counts writes a family of counts by column and one more label, sorted
+func (m *metricsWriter) counts(name, help, label string, columns []column, counts []map[string]int) {
+	m.family(name, "gauge", help)
+	for n := range columns {
+		keys := []string{}
+		for key := range counts[n] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			m.sample(name, float64(counts[n][key]), columnLabels(&columns[n], label, key)...)
+		}
+	}
+}
+
+// cardAdded is when a card was put on the board
+func cardAdded(c card) time.Time {
+	if i := cardIssue(c); i != nil {
+		return i.createdAt.Time
+	}
+	if n, isNote := c.(*note); isNote {
+		return n.createdAt.Time
+	}
+	return time.Time{}
+}
+
+// writeBoardMetrics writes the gauges of the cards in every column
+func writeBoardMetrics(m *metricsWriter, p *ProjectProxy, now time.Time) {
+	states := make([]map[string]int, len(p.columns))
+	assignees := make([]map[string]int, len(p.columns))
+	labels := make([]map[string]int, len(p.columns))
+	for n, col := range p.columns {
+		states[n], assignees[n], labels[n] = map[string]int{}, map[string]int{}, map[string]int{}
+		for _, c := range col.cards {
+			i := cardIssue(c)
+			if i == nil {
+				continue
+			}
+			states[n][i.ghIssue.GetState()]++
+			for _, login := range i.assigneeLogins() {
+				assignees[n][login]++
+			}
+			for _, label := range i.labelNames() {
+				labels[n][label]++
+			}
+		}
+	}
+
+	m.family("ghp_cards", "gauge", "Cards per column, notes included.")
+	for n := range p.columns {
+		m.sample("ghp_cards", float64(len(p.columns[n].cards)), columnLabels(&p.columns[n])...)
+	}
+	m.counts("ghp_state_cards", "Issues and pull requests per column and state.", "state", p.columns, states)
+	m.counts("ghp_assignee_cards", "Issues and pull requests per column and assignee.", "assignee", p.columns, assignees)
+	m.family("ghp_unassigned_cards", "gauge", "Issues and pull requests without assignee per column.")
+	for n := range p.columns {
+		col := &p.columns[n]
+		unassigned := 0
+		for _, c := range col.cards {
+			if i := cardIssue(c); i != nil && len(i.assigneeLogins()) == 0 {
+				unassigned++
+			}
+		}
+		m.sample("ghp_unassigned_cards", float64(unassigned), columnLabels(col)...)
+	}
+	m.counts("ghp_label_cards", "Issues and pull requests per column and label.", "label", p.columns, labels)
+
+	m.family("ghp_oldest_card_age_seconds", "gauge", "Time since the oldest card of the column was added to the board.")
+	for n := range p.columns {
+		col := &p.columns[n]
+		oldest := time.Time{}
+		for _, c := range col.cards {
+			added := cardAdded(c)
+			if !added.IsZero() && (oldest.IsZero() || added.Before(oldest)) {
+				oldest = added
+			}
+		}
+		if !oldest.IsZero() {
+			m.sample("ghp_oldest_card_age_seconds", now.Sub(oldest).Seconds(), columnLabels(col)...)
+		}
+	}
+
+	m.family("ghp_wip", "gauge", "Issues and pull requests in the columns with a wip limit.")
+	for n := range p.columns {
+		if col := &p.columns[n]; col.limit > 0 {
+			m.sample("ghp_wip", float64(col.wip()), columnLabels(col)...)
+		}
+	}
+	m.family("ghp_wip_limit", "gauge", "Wip limit of the columns that have one.")
+	for n := range p.columns {
+		if col := &p.columns[n]; col.limit > 0 {
+			m.sample("ghp_wip_limit", float64(col.limit), columnLabels(col)...)
+		}
+	}
+	m.family("ghp_wip_limit_breached", "gauge", "1 when the column has more work than its wip limit.")
+	for n := range p.columns {
+		if col := &p.columns[n]; col.limit > 0 {
+			breached := 0.0
+			if col.overLimit() {
+				breached = 1
+			}
+			m.sample("ghp_wip_limit_breached", breached, columnLabels(col)...)
+		}
+	}
+}
+
+// metrics serves the board and ghp's own metrics
+func (d *dashboard) metrics(w http.ResponseWriter, r *http.Request) {
+	p, done := d.current(w)
+	if p == nil {
+		return
+	}
+	defer done()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := &metricsWriter{w: w, project: d.env.state.DefaultProject}
+	writeBoardMetrics(m, p, time.Now())
+	m.family("ghp_board_loaded_timestamp_seconds", "gauge", "When the board was last loaded.")
+	m.sample("ghp_board_loaded_timestamp_seconds", float64(d.loaded.Unix()))
+
+	m.project = ""
+	client := d.env.client
+	m.family("ghp_api_requests_total", "counter", "Requests sent to the github api, retries included.")
+	m.sample("ghp_api_requests_total", float64(client.rateLimit.requestCount()))
+	boardHits, boardMisses := client.board.stats()
+	m.family("ghp_cache_hits_total", "counter", "Requests answered from a ghp cache.")
+	m.sample("ghp_cache_hits_total", float64(d.cacheHits), "cache", "memory")
+	m.sample("ghp_cache_hits_total", float64(boardHits), "cache", "board")
+	m.family("ghp_cache_misses_total", "counter", "Requests a ghp cache couldn't answer.")
+	m.sample("ghp_cache_misses_total", float64(d.cacheMisses), "cache", "memory")
+	m.sample("ghp_cache_misses_total", float64(boardMisses), "cache", "board")
+	limit, remaining, reset := client.rateLimit.quota()
+	if limit >= 0 {
+		m.family("ghp_rate_limit", "gauge", "Github api requests allowed per hour.")
+		m.sample("ghp_rate_limit", float64(limit))
+		m.family("ghp_rate_limit_remaining", "gauge", "Github api requests left until the reset.")
+		m.sample("ghp_rate_limit_remaining", float64(remaining))
+		m.family("ghp_rate_limit_reset_timestamp_seconds", "gauge", "When the github api quota resets.")
+		m.sample("ghp_rate_limit_reset_timestamp_seconds", float64(reset.Unix()))
+	}
+}
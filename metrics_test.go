@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+func TestWriteBoardMetrics(t *testing.T) {
+	now := time.Date(2020, 3, 30, 0, 0, 0, 0, time.UTC)
+	added := func(i *issue, days int) *issue {
+		i.createdAt = github.Timestamp{Time: now.AddDate(0, 0, -days)}
+		return i
+	}
+	assigned := added(testIssue(2, `say "hi"`), 3)
+	assigned.ghIssue.Assignees = []*github.User{{Login: github.String("ann")}}
+	closed := added(testIssue(4, "bug"), 10)
+	closed.ghIssue.State = github.String("closed")
+	p := &ProjectProxy{columns: []column{
+		{name: "To do", id: 1, limit: 1, cards: []card{added(testIssue(1, `back\slash`, "bug"), 5), assigned}},
+		{name: "To do", id: 2, cards: []card{&note{text: "plan", createdAt: github.Timestamp{Time: now.AddDate(0, 0, -1)}}}},
+		{name: "Done", id: 3, cards: []card{closed}},
+	}}
+
+	var out bytes.Buffer
+	writeBoardMetrics(&metricsWriter{w: &out, project: "team \"a\"\nboard"}, p, now)
+	golden := filepath.Join("testdata", "metrics", "board.prom")
+	if *updateGolden {
+		if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("got metrics\n%s\nwant\n%s", out.Bytes(), want)
+	}
+}
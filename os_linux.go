@@ -5,6 +5,7 @@ package main
 import (
 	"os"
 	"os/exec"
+	"strconv"
 
 	"golang.org/x/sys/unix"
 )
@@ -12,15 +13,38 @@ import (
 // USER_TOKEN token store filename
 const userState = ".ghp.state"
 
+// userCache file keeping the board index used by completions
+const userCache = ".ghp.cache"
+
+// userSnapshots directory of saved project snapshots
+const userSnapshots = ".ghp.snapshots"
+
+// userBoardCache file with the api responses kept fresh by 'ghp serve'
+const userBoardCache = ".ghp.board"
+
+// userAutomate file with the automate rules
+const userAutomate = ".ghp.automate.json"
+
 func openBrowser(url string) error {
 	err := exec.Command("xdg-open", url).Start()
 	return err
 }
 
+// consoleWidth returns the stdout terminal width, when output is not a
+// terminal $COLUMNS or 80 are used
 func consoleWidth() int {
-	ws, _ := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ)
-	if int(ws.Col) > 20 {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err == nil && int(ws.Col) > 20 {
 		return int(ws.Col)
 	}
-	return 150
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err == nil && columns > 20 {
+		return columns
+	}
+	return 80
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	return err == nil
 }
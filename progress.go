@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const progressInterval = 100 * time.Millisecond
+
+// progress draws a single status line on stderr while a project loads, it's
+// a no-op when disabled
+type progress struct {
+	enabled      bool
+	totalColumns int
+	columns      int
+	cards        int
+	column       string
+	cache        *appCache
+	lastDraw     time.Time
+}
+
+// newProgress returns a progress line shown only when stderr is a terminal
+func newProgress(cache *appCache, quiet bool) *progress {
+	return &progress{enabled: !quiet && isTerminal(os.Stderr), cache: cache}
+}
+
+func (p *progress) startColumn(name string, total int) {
+	if p == nil {
+		return
+	}
+	p.column = name
+	p.totalColumns = total
+	p.draw(true)
+}
+
+func (p *progress) cardDone() {
+	if p == nil {
+		return
+	}
+	p.cards++
+	p.draw(false)
+}
+
+func (p *progress) columnDone() {
+	if p == nil {
+		return
+	}
+	p.columns++
+	p.draw(true)
+}
+
+func (p *progress) draw(force bool) {
+	if !p.enabled || (!force && time.Since(p.lastDraw) < progressInterval) {
+		return
+	}
+	p.lastDraw = time.Now()
+	hits := 0
+	if p.cache != nil {
+		hits = p.cache.cacheHits
+	}
+	line := fmt.Sprintf("loading %v/%v columns, %v cards, %v cache hits", p.columns, p.totalColumns, p.cards, hits)
+	if p.column != "" && p.columns < p.totalColumns {
+		line += " (" + p.column + ")"
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[K"+ellipseStr(line, consoleWidth()-1))
+}
+
+// done clears the progress line
+func (p *progress) done() {
+	if p == nil || !p.enabled {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+}
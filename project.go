@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -20,12 +21,23 @@ type card interface {
 	match(filters [][]string) bool
 }
 
+// cardID returns the project card id from the card url
+func cardID(c card) (int64, error) {
+	id, err := strconv.ParseInt(lastPathElement(c.getURL()), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid card url %v", c.getURL())
+	}
+	return id, nil
+}
+
 type issue struct {
 	url       string
 	createdAt github.Timestamp
+	updatedAt github.Timestamp // of the card, moves update it
 	ghIssue   *github.Issue
 	//labels     []*github.Label
-	repository *github.Repository
+	repository  *github.Repository
+	linkedPulls []string
 }
 
 func (i issue) getURL() string {
@@ -39,9 +51,14 @@ func (i issue) toListString() string {
 		res += "(closed)"
 	}
 	res += " " + i.ghIssue.GetTitle() + " "
-	assignee := i.ghIssue.GetAssignee()
-	if assignee != nil {
-		res += "@" + assignee.GetLogin() + " "
+	for _, login := range i.assigneeLogins() {
+		res += "@" + login + " "
+	}
+	if i.ghIssue.Milestone != nil {
+		res += "milestone:" + i.ghIssue.GetMilestone().GetTitle() + " "
+	}
+	if len(i.linkedPulls) != 0 {
+		res += "linked:" + strings.Join(i.linkedPulls, ",") + " "
 	}
 	labels := i.ghIssue.Labels
 	if len(labels) != 0 {
@@ -58,23 +75,7 @@ func (i issue) match(filters [][]string) bool {
 	if len(filters) == 0 {
 		return true
 	}
-	issueString := "issue " + i.toListString()
-	if i.ghIssue.Assignee == nil {
-		issueString += " unassigned"
-	}
-	for _, orFilter := range filters {
-		subRes := true
-		for _, andFilter := range orFilter {
-			if !strings.Contains(issueString, andFilter) { // if AND subfilter fails one break and set false
-				subRes = false
-				break
-			}
-		}
-		if subRes {
-			return true // if only one OR filter is true return inmediately true
-		}
-	}
-	return false
+	return matchFilters("issue "+i.toListString(), i.qualifiers("issue"), filters)
 }
 
 func (i *issue) labelString() string {
@@ -102,6 +103,42 @@ func (i *issue) labelNames() []string {
 	return labelnames
 }
 
+// qualifiers shared by issues and pull requests: type:, state:, repo:,
+// assignee: or unassigned, label:, milestone: and comments:
+func (i *issue) qualifiers(kind string) []string {
+	res := []string{"type:" + kind, "state:" + i.ghIssue.GetState(), "repo:" + i.repository.GetName()}
+	for _, login := range i.assigneeLogins() {
+		res = append(res, "assignee:"+login)
+	}
+	if len(i.assigneeLogins()) == 0 {
+		res = append(res, "unassigned")
+	}
+	for _, label := range i.labelNames() {
+		res = append(res, "label:"+label)
+	}
+	if i.ghIssue.Milestone != nil {
+		res = append(res, "milestone:"+i.ghIssue.GetMilestone().GetTitle())
+	}
+	return append(res, "comments:"+strconv.Itoa(i.ghIssue.GetComments()))
+}
+
+// assigneeLogins returns every assignee, older payloads only have Assignee
+func (i *issue) assigneeLogins() []string {
+	logins := make([]string, 0, len(i.ghIssue.Assignees))
+	for _, user := range i.ghIssue.Assignees {
+		logins = append(logins, user.GetLogin())
+	}
+	if len(logins) == 0 && i.ghIssue.Assignee != nil {
+		logins = append(logins, i.ghIssue.GetAssignee().GetLogin())
+	}
+	return logins
+}
+
+// ref returns the short "repo#number" reference of the issue
+func (i *issue) ref() string {
+	return fmt.Sprintf("%v#%v", i.repository.GetName(), i.ghIssue.GetNumber())
+}
+
 func (i *issue) lenLabelString() int {
 	return utf8.RuneCountInString(i.labelString())
 }
@@ -110,6 +147,7 @@ type note struct {
 	url       string
 	text      string
 	createdAt github.Timestamp
+	updatedAt github.Timestamp
 }
 
 func (n note) getURL() string {
@@ -125,10 +163,38 @@ func (n note) match(filters [][]string) bool {
 	if len(filters) == 0 {
 		return true
 	}
+	return matchFilters("note "+n.text, []string{"type:note"}, filters)
+}
+
+// qualifierKeys are the filter terms matched whole against the qualifiers of
+// a card instead of as a part of its text
+var qualifierKeys = []string{"type:", "state:", "repo:", "assignee:", "label:", "milestone:", "comments:",
+	"review:", "ci:", "draft:", "mergeable:", "branch:"}
+
+// matchTerm checks a filter term, qualifiers like label:bug must equal one of
+// the card qualifiers so they don't match label:bug-report or a title
+func matchTerm(text string, qualifiers []string, term string) bool {
+	isQualifier := term == "unassigned"
+	for _, key := range qualifierKeys {
+		isQualifier = isQualifier || strings.HasPrefix(term, key)
+	}
+	if !isQualifier {
+		return strings.Contains(text, term)
+	}
+	for _, q := range qualifiers {
+		if strings.EqualFold(q, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFilters checks a card against OR groups of AND terms
+func matchFilters(text string, qualifiers []string, filters [][]string) bool {
 	for _, orFilter := range filters {
 		subRes := true
 		for _, andFilter := range orFilter {
-			if !strings.Contains("note "+n.text, andFilter) { // if AND subfilter fails one break and set false
+			if !matchTerm(text, qualifiers, andFilter) { // if AND subfilter fails one break and set false
 				subRes = false
 				break
 			}
@@ -145,22 +211,40 @@ type column struct {
 	url   string
 	id    int64
 	cards []card
+	limit int // wip limit, 0 for none
+}
+
+// wip counts the issues and pull requests in the column, notes aren't work
+func (c *column) wip() int {
+	count := 0
+	for _, card := range c.cards {
+		if cardIssue(card) != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// overLimit is true when the column has more work than its wip limit
+func (c *column) overLimit() bool {
+	return c.limit > 0 && c.wip() > c.limit
 }
 
 func (c *column) pullCards(p *ProjectProxy) error {
 	// log.Printf("pullig cards for %v", c.id)
 	cards, err := p.client.getAllColumnCards(c.id)
 	if err != nil {
-		return fmt.Errorf("%v", err)
+		return err
 	}
 	for _, card := range cards {
 		if !card.GetArchived() {
 			newCard, err := buildCard(p, card)
 			// log.Printf("new Card: %+v", newCard)
 			if err != nil {
-				return fmt.Errorf("error Getting card for %v: %v", c.id, err)
+				return fmt.Errorf("error Getting card for %v: %w", c.id, err)
 			}
 			c.cards = append(c.cards, newCard)
+			p.progress.cardDone()
 		}
 	}
 	return nil
@@ -168,9 +252,13 @@ func (c *column) pullCards(p *ProjectProxy) error {
 
 // ProjectProxy Class for interacting github's project
 type ProjectProxy struct {
-	client  *ghpClient
-	cache   *appCache
-	columns []column
+	client   *ghpClient
+	cache    *appCache
+	columns  []column
+	progress *progress
+	// linked fetches the pull requests referencing every issue, one more
+	// request per issue card
+	linked bool
 }
 
 func (p *ProjectProxy) requestAPI(url string, v interface{}, opts *cacheUseOptions) error {
@@ -205,28 +293,105 @@ func (p *ProjectProxy) getIssueByURL(url string) (*issue, error) {
 		return nil, err
 	}
 	pIssue.repository = repo
+	if p.linked && !i.IsPullRequest() {
+		pIssue.linkedPulls, err = p.getLinkedPulls(pIssue)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return pIssue, nil
 }
 
-func (p *ProjectProxy) pullColums(projectID int64) error {
+// getTimeline returns every timeline event of an issue or pull request,
+// oldest first. Timelines are paginated, busy issues have their latest
+// events past the first page
+func (p *ProjectProxy) getTimeline(i *issue, opts *cacheUseOptions) ([]*github.Timeline, error) {
+	url := i.ghIssue.GetURL() + "/timeline?per_page=100"
+	if !opts.ignoreCached {
+		if cached, ok := p.cache.get(url).(*[]*github.Timeline); ok {
+			return *cached, nil
+		}
+	}
+	events := []*github.Timeline{}
+	for next := url; next != ""; {
+		page := []*github.Timeline{}
+		var err error
+		next, err = p.client.getAPIPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+	}
+	if !opts.doNotStore {
+		p.cache.add(url, &events)
+	}
+	return events, nil
+}
+
+// getLinkedPulls looks for pull requests referencing the issue, pull requests
+// on the same repository are shortened to "#number"
+func (p *ProjectProxy) getLinkedPulls(i *issue) ([]string, error) {
+	// stored for stats, a new listing always reads it again
+	events, err := p.getTimeline(i, &cacheUseOptions{false, true})
+	if err != nil {
+		return nil, err
+	}
+	linked := []string{}
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.GetEvent() != "cross-referenced" {
+			continue
+		}
+		source := event.GetSource().GetIssue()
+		if source == nil || !source.IsPullRequest() {
+			continue
+		}
+		ref := fmt.Sprintf("#%v", source.GetNumber())
+		if source.GetRepositoryURL() != i.repository.GetURL() {
+			ref = source.GetRepository().GetName() + ref
+			if source.GetRepository().GetName() == "" {
+				ref = lastPathElement(source.GetRepositoryURL()) + ref
+			}
+		}
+		if !seen[ref] {
+			seen[ref] = true
+			linked = append(linked, ref)
+		}
+	}
+	return linked, nil
+}
+
+// pullColums reads the project columns and their cards, columns rejected by
+// wanted are skipped without fetching their cards. A nil wanted pulls all
+func (p *ProjectProxy) pullColums(projectID int64, wanted func(name string) bool) error {
 	// log.Printf("Pull columns %v", projectID)
+	defer p.progress.done()
 	cols, err := p.client.listColumns(projectID)
 	if err != nil {
-		return fmt.Errorf("%v", err)
+		return err
 	}
 	if len(cols) < 1 {
-		return fmt.Errorf("error getting columns for %v: Zero items", projectID)
+		return newError(errNotFound, "error getting columns for %v: Zero items", projectID)
 	}
+	// the progress total counts only the columns loaded
+	loaded := []*github.ProjectColumn{}
 	for _, c := range cols {
+		if wanted == nil || wanted(c.GetName()) {
+			loaded = append(loaded, c)
+		}
+	}
+	for _, c := range loaded {
 		col := new(column)
 		col.name = c.GetName()
 		col.id = c.GetID()
 		col.url = c.GetURL()
+		p.progress.startColumn(col.name, len(loaded))
 		err := col.pullCards(p)
 		if err != nil {
 			return err
 		}
 		p.columns = append(p.columns, *col)
+		p.progress.columnDone()
 	}
 	return nil
 }
@@ -259,6 +424,7 @@ func buildCard(p *ProjectProxy, c *github.ProjectCard) (card, error) {
 		n.text = noteText
 		n.url = url
 		n.createdAt = c.GetCreatedAt()
+		n.updatedAt = c.GetUpdatedAt()
 		return n, nil // returns note
 	}
 	i, err := p.getIssueByURL(c.GetContentURL())
@@ -267,5 +433,13 @@ func buildCard(p *ProjectProxy, c *github.ProjectCard) (card, error) {
 	}
 	i.url = url
 	i.createdAt = c.GetCreatedAt()
+	i.updatedAt = c.GetUpdatedAt()
+	if i.ghIssue.IsPullRequest() {
+		pr, err := p.getPullRequest(i)
+		if err != nil {
+			return nil, err
+		}
+		return pr, nil // returns pull request
+	}
 	return i, nil // returns issue
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// maxProjectLoads is how many boards are loaded at the same time
+const maxProjectLoads = 4
+
+// projectBoard is a loaded project of the organization
+type projectBoard struct {
+	name  string
+	id    int64
+	board *ProjectProxy
+}
+
+// projectFlags adds the flags choosing the projects to load instead of the
+// default one
+func projectFlags(fs *flag.FlagSet, env *cmdEnv, all bool) {
+	fs.Var((*columnFlags)(&env.projects), "projects", "Projects to load, by name or id, can be repeated or comma separated")
+	if all {
+		fs.BoolVar(&env.allProjects, "all-projects", false, "Load every open project of the organization")
+	}
+}
+
+// findProjects returns the open projects of the organization named by id or
+// name in the given order, all of them when names is empty
+func findProjects(state *ghpConfig, client *ghpClient, names []string) ([]*github.Project, error) {
+	projects, err := client.listProjects(state.Organization)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		if len(projects) == 0 {
+			return nil, newError(errNotFound, "no projects in %v", state.Organization)
+		}
+		return projects, nil
+	}
+	found := []*github.Project{}
+	seen := map[int64]bool{}
+	for _, name := range names {
+		id, _ := strconv.ParseInt(name, 10, 64)
+		var match *github.Project
+		for _, project := range projects {
+			if project.GetID() == id || strings.EqualFold(project.GetName(), name) {
+				match = project
+				break
+			}
+		}
+		if match == nil {
+			return nil, newError(errNotFound, "no project %v in %v", name, state.Organization)
+		}
+		if !seen[match.GetID()] {
+			seen[match.GetID()] = true
+			found = append(found, match)
+		}
+	}
+	return found, nil
+}
+
+// loadProjects loads several boards at once keeping their order. The boards
+// share the cache but count their hits apart, the board index used by
+// completions is left to the default project
+func loadProjects(state ghpConfig, cache *appCache, client *ghpClient, opts *listOptions, projects []*github.Project) ([]projectBoard, error) {
+	boards := make([]projectBoard, len(projects))
+	errs := make([]error, len(projects))
+	slots := make(chan struct{}, maxProjectLoads)
+	var wg sync.WaitGroup
+	for n, project := range projects {
+		wg.Add(1)
+		go func(n int, project *github.Project) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			p := &ProjectProxy{client: client, cache: &appCache{cache: cache.cache}, linked: opts.linked}
+			err := p.pullColums(project.GetID(), opts.showColumn)
+			if err != nil {
+				errs[n] = fmt.Errorf("error reading project %v: %w", project.GetName(), err)
+				return
+			}
+			for c := range p.columns {
+				p.columns[c].limit = wipLimit(state, p.columns[c].name)
+			}
+			boards[n] = projectBoard{name: project.GetName(), id: project.GetID(), board: p}
+		}(n, project)
+	}
+	wg.Wait()
+	for n, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		cache.cacheHits += boards[n].board.cache.cacheHits
+		cache.cacheMiss += boards[n].board.cache.cacheMiss
+	}
+	return boards, nil
+}
+
+// matchCount is the number of listed cards of a board
+func matchCount(p *ProjectProxy, filter [][]string, opts *listOptions) int {
+	count := 0
+	for _, col := range p.columns {
+		if !opts.showColumn(col.name) {
+			continue
+		}
+		for _, c := range col.cards {
+			if c.match(filter) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// doListProjects lists several projects, each under its name. With
+// hideEmpty the projects and columns without matching cards are left out
+func doListProjects(state ghpConfig, cache *appCache, client *ghpClient, f filterFlags, opts *listOptions, names []string) error {
+	projects, err := findProjects(&state, client, names)
+	if err != nil {
+		return err
+	}
+	if !opts.quiet {
+		fmt.Printf("Requesting %v projects of %v, this can take some time\n", len(projects), state.Organization)
+	}
+	boards, err := loadProjects(state, cache, client, opts, projects)
+	if err != nil {
+		return err
+	}
+	if len(f) != 0 && !opts.quiet {
+		fmt.Printf("Applying filters: %v\n", f.String())
+	}
+	filters := f.toFilters(state.User)
+	shown := 0
+	for _, b := range boards {
+		count := matchCount(b.board, filters, opts)
+		if opts.hideEmpty && count == 0 {
+			continue
+		}
+		fmt.Printf("\n%v %v\n", singleColorHub.headerColorize("== "+b.name), singleColorHub.styled(singleColorHub.theme.Muted, fmt.Sprintf("(%v)", count)))
+		fancyList(b.board, filters, opts)
+		shown++
+	}
+	if opts.quiet {
+		return nil
+	}
+	if shown == 0 {
+		fmt.Println("\nNo cards found")
+	}
+	fmt.Printf("\ncache performance:\nHits: %v\nMiss:%v\n", cache.cacheHits, cache.cacheMiss)
+	limit, remaining, reset := client.rateLimit.quota()
+	if limit >= 0 {
+		fmt.Printf("API quota: %v/%v until %v\n", remaining, limit, reset.Format("15:04:05"))
+	}
+	return nil
+}
+
+// mineFilters restricts every filter to the cards assigned to the user
+func mineFilters(f filterFlags) filterFlags {
+	if len(f) == 0 {
+		return filterFlags{"assignee:@me"}
+	}
+	mine := filterFlags{}
+	for _, filter := range f {
+		mine = append(mine, filter+",assignee:@me")
+	}
+	return mine
+}
+
+func init() {
+	registerCommand(&ghpCommand{
+		name:    "mine",
+		summary: "List the cards assigned to you in every project",
+		description: `
+Loads the open projects of the organization at once, or the ones given with
+-projects, and lists the cards assigned to the configured user grouped by
+project and column. Projects and columns without cards of yours are left
+out. -filter narrows the cards further, a saved view named mine is still
+listed with 'ghp list -v mine'.`,
+		examples: []string{
+			"ghp mine",
+			"ghp mine -exclude-column Done",
+			"ghp mine -projects Backend,Frontend -filter label:urgent",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			linkedFlag(fs, env)
+			projectFlags(fs, env, false)
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help mine'", strings.Join(args, " "))
+			}
+			err := env.prepareList()
+			if err != nil {
+				return err
+			}
+			err = checkAllConfig(env.state, env.client)
+			if err != nil {
+				return err
+			}
+			env.opts.hideEmpty = true
+			return doListProjects(*env.state, env.cache, env.client, mineFilters(env.filters), env.opts, env.projects)
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func TestMineFilters(t *testing.T) {
+	assigned := func(number int, logins ...string) *issue {
+		i := &issue{repository: &github.Repository{Name: github.String("api")}}
+		i.ghIssue = &github.Issue{Number: github.Int(number), State: github.String("open"), Title: github.String("mentions bob")}
+		for _, login := range logins {
+			i.ghIssue.Assignees = append(i.ghIssue.Assignees, &github.User{Login: github.String(login)})
+		}
+		return i
+	}
+	cards := []card{assigned(1, "bob"), assigned(2, "bobby"), assigned(3, "bob-ci"), assigned(4, "ann", "Bob"),
+		assigned(5), &note{text: "ask assignee:bob"}}
+	tests := []struct {
+		name    string
+		filters filterFlags
+		want    []bool
+	}{
+		{"mine", nil, []bool{true, false, false, true, false, false}},
+		{"mine narrowed", filterFlags{"repo:api"}, []bool{true, false, false, true, false, false}},
+		{"mine in another repo", filterFlags{"repo:ap"}, []bool{false, false, false, false, false, false}},
+	}
+	for _, test := range tests {
+		mine := mineFilters(test.filters)
+		filters := mine.toFilters("bob")
+		for n, c := range cards {
+			if got := c.match(filters); got != test.want[n] {
+				t.Errorf("%v: card %v matches %v, want %v", test.name, n+1, got, test.want[n])
+			}
+		}
+	}
+}
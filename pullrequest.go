@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// review decisions, computed from the latest review of every reviewer
+const (
+	reviewApproved         = "approved"
+	reviewChangesRequested = "changes_requested"
+	reviewRequired         = "required"
+	reviewNone             = "none"
+)
+
+// combined CI states from commit statuses and check runs
+const (
+	ciPassing = "passing"
+	ciFailing = "failing"
+	ciPending = "pending"
+	ciNone    = "none"
+)
+
+type pullRequest struct {
+	issue
+	ghPull         *github.PullRequest
+	reviewDecision string
+	ciState        string
+}
+
+func (pr pullRequest) toListString() string {
+	res := "pr: "
+	res += fmt.Sprintf("%v#%v", pr.repository.GetName(), pr.ghIssue.GetNumber())
+	if pr.ghIssue.GetState() == "closed" {
+		if pr.ghPull.GetMerged() {
+			res += "(merged)"
+		} else {
+			res += "(closed)"
+		}
+	}
+	if pr.ghPull.GetDraft() {
+		res += "(draft)"
+	}
+	res += " " + pr.ghIssue.GetTitle() + " "
+	res += "[" + pr.ghPull.GetHead().GetRef() + "] "
+	for _, login := range pr.assigneeLogins() {
+		res += "@" + login + " "
+	}
+	if pr.ghIssue.Milestone != nil {
+		res += "milestone:" + pr.ghIssue.GetMilestone().GetTitle() + " "
+	}
+	reviewers := pr.requestedReviewers()
+	if len(reviewers) != 0 {
+		res += "review:@" + strings.Join(reviewers, ",@") + " "
+	}
+	res += pr.labelString()
+	return res
+}
+
+func (pr pullRequest) match(filters [][]string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	return matchFilters("pr "+pr.toListString(), pr.qualifiers(), filters)
+}
+
+// qualifiers adds the pull request ones: review:<decision> and
+// review:@<requested reviewer>, ci:<state>, draft:<bool>, mergeable:<bool>
+// and branch:<head ref>
+func (pr pullRequest) qualifiers() []string {
+	res := pr.issue.qualifiers("pr")
+	res = append(res, "review:"+pr.reviewDecision)
+	for _, login := range pr.requestedReviewers() {
+		res = append(res, "review:@"+login)
+	}
+	return append(res, "ci:"+pr.ciState, "draft:"+strconv.FormatBool(pr.ghPull.GetDraft()),
+		"mergeable:"+strconv.FormatBool(pr.ghPull.GetMergeable()), "branch:"+pr.ghPull.GetHead().GetRef())
+}
+
+func (pr *pullRequest) requestedReviewers() []string {
+	reviewers := make([]string, 0, len(pr.ghPull.RequestedReviewers))
+	for _, user := range pr.ghPull.RequestedReviewers {
+		reviewers = append(reviewers, user.GetLogin())
+	}
+	return reviewers
+}
+
+// conflicting is true when github already knows the branch can't be merged
+func (pr *pullRequest) conflicting() bool {
+	return pr.ghPull.GetMergeableState() == "dirty"
+}
+
+// reviewDecisionFor mimics github's review decision using only the latest
+// review of each user, comments don't change the decision
+func reviewDecisionFor(reviews []*github.PullRequestReview, requested int) string {
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		state := review.GetState()
+		if state == "COMMENTED" || state == "PENDING" {
+			continue
+		}
+		latest[review.GetUser().GetLogin()] = state
+	}
+	decision := reviewNone
+	for _, state := range latest {
+		switch state {
+		case "CHANGES_REQUESTED":
+			return reviewChangesRequested
+		case "APPROVED":
+			decision = reviewApproved
+		}
+	}
+	if decision == reviewNone && requested > 0 {
+		decision = reviewRequired
+	}
+	return decision
+}
+
+// ciStateFor merges the legacy combined status with check runs, any failure
+// wins over pending and pending wins over success
+func ciStateFor(status *github.CombinedStatus, checks *github.ListCheckRunsResults) string {
+	failing, pending, passing := false, false, false
+	if status.GetTotalCount() > 0 {
+		switch status.GetState() {
+		case "success":
+			passing = true
+		case "pending":
+			pending = true
+		default:
+			failing = true
+		}
+	}
+	for _, run := range checks.CheckRuns {
+		if run.GetStatus() != "completed" {
+			pending = true
+			continue
+		}
+		switch run.GetConclusion() {
+		case "success", "neutral", "skipped":
+			passing = true
+		default:
+			failing = true
+		}
+	}
+	switch {
+	case failing:
+		return ciFailing
+	case pending:
+		return ciPending
+	case passing:
+		return ciPassing
+	}
+	return ciNone
+}
+
+// getPullRequest completes an already fetched issue with its pull request
+// details, reviews and CI status
+func (p *ProjectProxy) getPullRequest(i *issue) (*pullRequest, error) {
+	ghPull := new(github.PullRequest)
+	err := p.requestAPI(i.ghIssue.GetPullRequestLinks().GetURL(), ghPull, &cacheUseOptions{true, true})
+	if err != nil {
+		return nil, err
+	}
+	pr := new(pullRequest)
+	pr.issue = *i
+	pr.ghPull = ghPull
+
+	reviews := []*github.PullRequestReview{}
+	err = p.requestAPI(ghPull.GetURL()+"/reviews", &reviews, &cacheUseOptions{true, true})
+	if err != nil {
+		return nil, err
+	}
+	pr.reviewDecision = reviewDecisionFor(reviews, len(ghPull.RequestedReviewers)+len(ghPull.RequestedTeams))
+
+	commitURL := ghPull.GetBase().GetRepo().GetURL() + "/commits/" + ghPull.GetHead().GetSHA()
+	status := new(github.CombinedStatus)
+	err = p.requestAPI(commitURL+"/status", status, &cacheUseOptions{true, true})
+	if err != nil {
+		return nil, err
+	}
+	checks := new(github.ListCheckRunsResults)
+	err = p.requestAPI(commitURL+"/check-runs", checks, &cacheUseOptions{true, true})
+	if err != nil {
+		return nil, err
+	}
+	pr.ciState = ciStateFor(status, checks)
+	return pr, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func testReview(user, state string) *github.PullRequestReview {
+	return &github.PullRequestReview{User: &github.User{Login: github.String(user)}, State: github.String(state)}
+}
+
+func TestReviewDecisionFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		reviews   []*github.PullRequestReview
+		requested int
+		want      string
+	}{
+		{"no reviews", nil, 0, reviewNone},
+		{"requested", nil, 2, reviewRequired},
+		{"only comments", []*github.PullRequestReview{testReview("ann", "COMMENTED")}, 1, reviewRequired},
+		{"approved", []*github.PullRequestReview{testReview("ann", "APPROVED")}, 0, reviewApproved},
+		{"approved with more requested", []*github.PullRequestReview{testReview("ann", "APPROVED")}, 1, reviewApproved},
+		{
+			"changes requested wins",
+			[]*github.PullRequestReview{testReview("ann", "APPROVED"), testReview("bob", "CHANGES_REQUESTED")},
+			0, reviewChangesRequested,
+		},
+		{
+			"latest review of the user counts",
+			[]*github.PullRequestReview{testReview("ann", "CHANGES_REQUESTED"), testReview("ann", "APPROVED")},
+			0, reviewApproved,
+		},
+		{
+			"comments don't dismiss",
+			[]*github.PullRequestReview{testReview("ann", "CHANGES_REQUESTED"), testReview("ann", "COMMENTED")},
+			0, reviewChangesRequested,
+		},
+		{"pending is ignored", []*github.PullRequestReview{testReview("ann", "PENDING")}, 0, reviewNone},
+	}
+	for _, test := range tests {
+		if got := reviewDecisionFor(test.reviews, test.requested); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func testCheckRuns(runs ...[2]string) *github.ListCheckRunsResults {
+	checks := &github.ListCheckRunsResults{}
+	for _, run := range runs {
+		checks.CheckRuns = append(checks.CheckRuns, &github.CheckRun{Status: github.String(run[0]), Conclusion: github.String(run[1])})
+	}
+	checks.Total = github.Int(len(checks.CheckRuns))
+	return checks
+}
+
+func TestCIStateFor(t *testing.T) {
+	status := func(state string, count int) *github.CombinedStatus {
+		return &github.CombinedStatus{State: github.String(state), TotalCount: github.Int(count)}
+	}
+	tests := []struct {
+		name   string
+		status *github.CombinedStatus
+		checks *github.ListCheckRunsResults
+		want   string
+	}{
+		{"nothing", nil, testCheckRuns(), ciNone},
+		{"no statuses", status("pending", 0), testCheckRuns(), ciNone},
+		{"status success", status("success", 1), testCheckRuns(), ciPassing},
+		{"status pending", status("pending", 1), testCheckRuns(), ciPending},
+		{"status failure", status("failure", 1), testCheckRuns(), ciFailing},
+		{"checks passing", nil, testCheckRuns([2]string{"completed", "success"}, [2]string{"completed", "skipped"}), ciPassing},
+		{"check running", status("success", 1), testCheckRuns([2]string{"in_progress", ""}), ciPending},
+		{"failure wins over pending", status("pending", 1), testCheckRuns([2]string{"completed", "timed_out"}), ciFailing},
+		{"neutral passes", nil, testCheckRuns([2]string{"completed", "neutral"}), ciPassing},
+	}
+	for _, test := range tests {
+		if got := ciStateFor(test.status, test.checks); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// report formats for --format
+var reportFormats = []string{"markdown", "html"}
+
+// reportCard is a card as shown in reports
+type reportCard struct {
+	Ref       string
+	URL       string
+	Title     string
+	Closed    bool
+	Note      bool
+	Column    string
+	Assignees []string
+	Labels    []reportLabel
+	Milestone string
+	Status    []string // pull request review, ci, conflicts and draft
+}
+
+// reportLabel is a label chip, colors are css colors
+type reportLabel struct {
+	Name       string
+	Background string
+	Foreground string
+}
+
+type reportSection struct {
+	Name  string
+	Cards []reportCard
+}
+
+type report struct {
+	Project   string
+	Generated string
+	Filters   string
+	View      string
+	Total     int
+	Lanes     bool // sections aren't columns, cards show their column
+	Sections  []reportSection
+}
+
+// Meta joins the pull request status, milestone and, for lanes, column
+func (c reportCard) Meta(lanes bool) string {
+	meta := append([]string{}, c.Status...)
+	if c.Milestone != "" {
+		meta = append(meta, "⚑ "+c.Milestone)
+	}
+	if lanes {
+		meta = append(meta, c.Column)
+	}
+	return strings.Join(meta, " · ")
+}
+
+func newReportCard(entry listEntry) reportCard {
+	c := reportCard{Column: entry.column, Title: cardTitle(entry.card)}
+	i := cardIssue(entry.card)
+	if i == nil {
+		c.Note = true
+		c.Ref = "note"
+		return c
+	}
+	c.Ref = i.ref()
+	c.URL = i.ghIssue.GetHTMLURL()
+	c.Closed = i.ghIssue.GetState() == "closed"
+	c.Assignees = i.assigneeLogins()
+	c.Milestone = i.ghIssue.GetMilestone().GetTitle()
+	for _, label := range i.ghIssue.Labels {
+		chip := reportLabel{Name: label.GetName(), Background: "#ededed", Foreground: "#000000"}
+		if rgb, valid := parseHexColor(label.GetColor()); valid {
+			chip.Background = "#" + strings.ToLower(label.GetColor())
+			if luminance(rgb) < 0.5 {
+				chip.Foreground = "#ffffff"
+			}
+		}
+		c.Labels = append(c.Labels, chip)
+	}
+	if pr, isPull := entry.card.(*pullRequest); isPull {
+		if pr.reviewDecision != reviewNone && pr.reviewDecision != "" {
+			c.Status = append(c.Status, "review: "+strings.ReplaceAll(pr.reviewDecision, "_", " "))
+		}
+		if pr.ciState != ciNone && pr.ciState != "" {
+			c.Status = append(c.Status, "ci: "+pr.ciState)
+		}
+		if pr.conflicting() {
+			c.Status = append(c.Status, "conflicts")
+		}
+		if pr.ghPull.GetDraft() {
+			c.Status = append(c.Status, "draft")
+		}
+	}
+	return c
+}
+
+// buildReport collects the listed cards, the same ones fancyList shows
+func buildReport(state ghpConfig, p *ProjectProxy, f filterFlags, view string, opts *listOptions) *report {
+	r := &report{
+		Project:   state.DefaultProject,
+		Generated: time.Now().Format("2006-01-02 15:04 MST"),
+		View:      view,
+		Lanes:     opts.groupBy != "" && opts.groupBy != "column",
+	}
+	if len(f) != 0 {
+		r.Filters = f.String()
+	}
+	for _, section := range buildSections(p, f.toFilters(state.User), opts) {
+		rs := reportSection{Name: section.name}
+		for _, entry := range section.entries {
+			rs.Cards = append(rs.Cards, newReportCard(entry))
+		}
+		r.Total += len(rs.Cards)
+		r.Sections = append(r.Sections, rs)
+	}
+	return r
+}
+
+// markdownEscape escapes the characters markdown would format in titles,
+// mentions are broken with a zero width space
+func markdownEscape(str string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+		"<", "&lt;", ">", "&gt;", "|", `\|`, "@", "@\u200b")
+	return replacer.Replace(str)
+}
+
+// writeMarkdown renders the report, assignees go in code spans so pasting it
+// in an issue doesn't mention everybody
+func writeMarkdown(w io.Writer, r *report) {
+	fmt.Fprintf(w, "# %v\n\n", markdownEscape(r.Project))
+	details := []string{fmt.Sprintf("%v cards", r.Total), "generated " + r.Generated}
+	if r.View != "" {
+		details = append(details, "view "+markdownEscape(r.View))
+	}
+	if r.Filters != "" {
+		details = append(details, "filters `"+r.Filters+"`")
+	}
+	fmt.Fprintf(w, "_%v_\n", strings.Join(details, " · "))
+	for _, section := range r.Sections {
+		fmt.Fprintf(w, "\n## %v (%v)\n\n", markdownEscape(section.Name), len(section.Cards))
+		if len(section.Cards) == 0 {
+			fmt.Fprintln(w, "_No cards_")
+		}
+		for _, c := range section.Cards {
+			if c.Note {
+				fmt.Fprintf(w, "- 📝 %v\n", markdownEscape(c.Title))
+				continue
+			}
+			title := markdownEscape(c.Title)
+			if c.Closed {
+				title = "~~" + title + "~~"
+			}
+			line := fmt.Sprintf("- [%v](%v) %v", c.Ref, c.URL, title)
+			for _, label := range c.Labels {
+				line += " `" + label.Name + "`"
+			}
+			for _, status := range c.Status {
+				line += " _" + status + "_"
+			}
+			if c.Milestone != "" {
+				line += " ⚑ " + markdownEscape(c.Milestone)
+			}
+			if len(c.Assignees) != 0 {
+				line += " — `@" + strings.Join(c.Assignees, "` `@") + "`"
+			}
+			if r.Lanes {
+				line += " (" + markdownEscape(c.Column) + ")"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Project}}</title>{{block "head" .}}{{end}}
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; background: #fafbfc; }
+header p { color: #586069; }
+main { display: flex; flex-wrap: wrap; gap: 1em; align-items: flex-start; }
+section { background: #eff1f3; border-radius: 6px; padding: 0.5em; width: 20em; }
+h2 { font-size: 1em; margin: 0.3em 0.3em 0.6em; }
+h2 .count { color: #586069; font-weight: normal; }
+.card { background: #fff; border: 1px solid #e1e4e8; border-radius: 6px; padding: 0.5em; margin-bottom: 0.5em; font-size: 0.9em; }
+.card a { color: #0366d6; text-decoration: none; }
+.closed .title { text-decoration: line-through; color: #6a737d; }
+.note { white-space: pre-wrap; }
+.chip { display: inline-block; border-radius: 1em; padding: 0 0.6em; margin: 0.2em 0.2em 0 0; font-size: 0.85em; }
+.assignee { background: #e1e4e8; }
+.meta { color: #586069; font-size: 0.85em; margin-top: 0.3em; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Project}}</h1>
+<p>{{.Total}} cards · generated {{.Generated}}{{if .View}} · view {{.View}}{{end}}{{if .Filters}} · filters <code>{{.Filters}}</code>{{end}}</p>{{block "controls" .}}{{end}}
+</header>
+<main>
+{{- range .Sections}}
+<section>
+<h2>{{.Name}} <span class="count">{{len .Cards}}</span></h2>
+{{- range .Cards}}
+{{- if .Note}}
+<div class="card note">{{.Title}}</div>
+{{- else}}
+<div class="card{{if .Closed}} closed{{end}}">
+<a href="{{.URL}}">{{.Ref}}</a> <span class="title">{{.Title}}</span>
+<div>
+{{- range .Labels}}<span class="chip" style="background: {{.Background}}; color: {{.Foreground}}">{{.Name}}</span>{{end}}
+{{- range .Assignees}}<span class="chip assignee">@{{.}}</span>{{end}}
+</div>
+{{- with .Meta $.Lanes}}
+<div class="meta">{{.}}</div>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+</section>
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+func doReport(env *cmdEnv, format, output string) error {
+	if !contains(reportFormats, format) {
+		return newError(errInvalidInput, "invalid format %v, use one of %v", format, strings.Join(reportFormats, ", "))
+	}
+	err := env.prepareList()
+	if err != nil {
+		return err
+	}
+	err = checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	p, err := loadProject(*env.state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	r := buildReport(*env.state, p, env.filters, env.viewName, env.opts)
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if format == "html" {
+		err = htmlReport.Execute(w, r)
+		if err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+	} else {
+		writeMarkdown(w, r)
+	}
+	if output != "" && !env.opts.quiet {
+		fmt.Fprintf(os.Stderr, "Report written to %v\n", output)
+	}
+	return nil
+}
+
+func init() {
+	var format, output string
+	registerCommand(&ghpCommand{
+		name:    "report",
+		summary: "Render the board as a markdown or html document",
+		description: `
+The report has a section per column, or per group with -group-by, with links
+to the issues, labels, assignees and card counts. List flags and saved views
+select the cards like in 'ghp list'.`,
+		examples: []string{
+			"ghp report > status.md",
+			"ghp report -v mywork -format html -output board.html",
+			"ghp report -exclude-column Done -group-by assignee",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			fs.StringVar(&format, "format", "markdown", "Report format: "+strings.Join(reportFormats, " or "))
+			fs.StringVar(&output, "output", "", "Write the report to this file instead of stdout")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help report'", strings.Join(args, " "))
+			}
+			return doReport(env, format, output)
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestMarkdownEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Plain title", "Plain title"},
+		{"Fix *bold* and _italic_", `Fix \*bold\* and \_italic\_`},
+		{"Use `code` here", "Use \\`code\\` here"},
+		{"[link](http://x)", `\[link\](http://x)`},
+		{"a | b", `a \| b`},
+		{"<script>", "&lt;script&gt;"},
+		{`back\slash`, `back\\slash`},
+		{"ping @ann", "ping @​ann"},
+	}
+	for _, test := range tests {
+		if got := markdownEscape(test.in); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// largest webhook payload github sends
+const maxWebhookPayload = 25 << 20
+
+// read timeouts of the servers, so clients that never finish a request
+// can't hold connections. The largest deliveries take well under a minute
+const (
+	serveHeaderTimeout = 10 * time.Second
+	serveReadTimeout   = time.Minute
+)
+
+// webhook events that change the board
+var webhookEvents = []string{"project_card", "project_column", "issues", "pull_request"}
+
+// serveOptions are the serve flags
+type serveOptions struct {
+	webhook  string
+	secret   string
+	automate bool
+	rules    string
+	resync   time.Duration
+	ui       string
+	write    bool
+	token    string
+	refresh  time.Duration
+	metrics  string
+}
+
+// webhookServer receives webhook deliveries and refetches what they changed
+// into the board cache
+type webhookServer struct {
+	env    *cmdEnv
+	cache  *boardCache
+	secret []byte
+	rules  []automateRule // nil without -automate
+	wake   chan struct{}
+	loaded func(p *ProjectProxy)
+
+	mu      sync.Mutex
+	pending []string         // urls to refetch
+	columns map[int64]string // card list urls by column id
+
+	// moves made by the rules since the last full sync by card url. Until
+	// then the rules don't repeat a move nor send a card back to a column
+	// they took it out of, so they can't loop
+	automated map[string][]automatedMove
+}
+
+// automatedMove is a move made by the rules, by column name
+type automatedMove struct {
+	from string
+	to   string
+}
+
+// validSignature checks the X-Hub-Signature-256 HMAC of a delivery, or the
+// older sha1 X-Hub-Signature
+func validSignature(header http.Header, body, secret []byte) bool {
+	signature, newHash := header.Get("X-Hub-Signature-256"), sha256.New
+	if signature == "" {
+		signature, newHash = header.Get("X-Hub-Signature"), sha1.New
+	}
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	sum, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// serveLogf logs server activity on stdout
+func serveLogf(format string, args ...interface{}) {
+	fmt.Printf("%v %v\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// projectURL is the api url of the default project
+func (s *webhookServer) projectURL() string {
+	return fmt.Sprintf("%vprojects/%v", s.env.client.apiClient.BaseURL, s.env.state.DefaultProjectID)
+}
+
+// cardLists returns the card list urls of the loaded columns
+func (s *webhookServer) cardLists() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := []string{}
+	for _, url := range s.columns {
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// stale returns the urls a delivery changed, nil when it isn't about the
+// board. Card events refetch every column since moves don't tell where the
+// card was
+func (s *webhookServer) stale(payload interface{}) []string {
+	switch event := payload.(type) {
+	case *github.ProjectCardEvent:
+		c := event.GetProjectCard()
+		s.mu.Lock()
+		_, known := s.columns[c.GetColumnID()]
+		s.mu.Unlock()
+		if !known && c.GetProjectURL() != s.projectURL() {
+			return nil
+		}
+		urls := s.cardLists()
+		if c.GetContentURL() != "" {
+			urls = append(urls, c.GetContentURL())
+		}
+		return urls
+	case *github.ProjectColumnEvent:
+		if event.GetProjectColumn().GetProjectURL() != s.projectURL() {
+			return nil
+		}
+		return append(s.cardLists(), s.projectURL()+"/columns")
+	case *github.IssuesEvent:
+		url := event.GetIssue().GetURL()
+		if !s.cache.has(url) {
+			return nil
+		}
+		return []string{url}
+	case *github.PullRequestEvent:
+		pr := event.GetPullRequest()
+		if !s.cache.has(pr.GetIssueURL()) {
+			return nil
+		}
+		return []string{pr.GetIssueURL(), pr.GetURL()}
+	}
+	return nil
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "webhook deliveries are POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, "error reading payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(r.Header, body, s.secret) {
+		serveLogf("rejected delivery %v from %v, invalid signature", r.Header.Get("X-GitHub-Delivery"), r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		fmt.Fprintln(w, "pong")
+		return
+	}
+	if !contains(webhookEvents, event) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "ignored %v event\n", event)
+		return
+	}
+	payload, err := github.ParseWebHook(event, body)
+	if err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	urls := s.stale(payload)
+	w.WriteHeader(http.StatusAccepted)
+	if urls == nil {
+		fmt.Fprintf(w, "ignored %v event, not on the board\n", event)
+		return
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, urls...)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	fmt.Fprintf(w, "queued %v event\n", event)
+}
+
+// sync refetches the pending urls, or everything with full, and saves the
+// board cache. Rules run on the new board, their moves come back as events
+func (s *webhookServer) sync(full bool) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if full {
+		s.cache.clear()
+		s.automated = map[string][]automatedMove{}
+	}
+	dropped := 0
+	for _, url := range pending {
+		dropped += s.cache.invalidate(url)
+	}
+	start := time.Now()
+	s.cache.startLoad()
+	state := *s.env.state
+	p, err := loadProject(state, s.env.cache, s.env.client, &listOptions{quiet: true})
+	if err != nil {
+		return err
+	}
+	s.cache.prune()
+	columns := map[int64]string{}
+	cards := 0
+	for _, col := range p.columns {
+		columns[col.id] = col.url + "/cards"
+		cards += len(col.cards)
+	}
+	s.mu.Lock()
+	s.columns = columns
+	s.mu.Unlock()
+	err = s.cache.save()
+	if err != nil {
+		return err
+	}
+	if full {
+		serveLogf("loaded %v cards in %v columns in %v", cards, len(columns), time.Since(start).Round(time.Millisecond))
+	} else {
+		serveLogf("refetched %v responses in %v", dropped, time.Since(start).Round(time.Millisecond))
+	}
+	// the rules read the board before the dashboard gets it, its moves
+	// change the columns
+	moves, err := s.planMoves(p, state.User)
+	if s.loaded != nil {
+		s.loaded(p)
+	}
+	if err != nil {
+		return err
+	}
+	return applyAutomation(s.env.client, moves, func(m automateMove) {
+		url := m.card.getURL()
+		s.automated[url] = append(s.automated[url], automatedMove{from: m.from.name, to: m.to.name})
+		serveLogf("moved %v from %v to %v (%v)", cardName(m.card), m.from.name, m.to.name, m.rule)
+	})
+}
+
+// planMoves returns the moves of the rules but the ones repeating a move or
+// sending a card back to a column the rules took it out of
+func (s *webhookServer) planMoves(p *ProjectProxy, me string) ([]automateMove, error) {
+	if s.rules == nil {
+		return nil, nil
+	}
+	moves, warnings, err := planAutomation(p, me, s.rules, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range warnings {
+		serveLogf("warning: %v", msg)
+	}
+	allowed := []automateMove{}
+	for _, m := range moves {
+		loops := false
+		for _, done := range s.automated[m.card.getURL()] {
+			loops = loops || done.from == m.to.name || (done.from == m.from.name && done.to == m.to.name)
+		}
+		if loops {
+			serveLogf("not moving %v from %v to %v (%v), it repeats or undoes a move of the rules until the next resync",
+				cardName(m.card), m.from.name, m.to.name, m.rule)
+			continue
+		}
+		allowed = append(allowed, m)
+	}
+	return allowed, nil
+}
+
+// loop syncs the queued deliveries one batch at a time until ctx is done
+func (s *webhookServer) loop(ctx context.Context, resync time.Duration) {
+	heartbeat := time.NewTicker(boardCacheHeartbeat)
+	defer heartbeat.Stop()
+	var resyncs <-chan time.Time
+	if resync > 0 {
+		ticker := time.NewTicker(resync)
+		defer ticker.Stop()
+		resyncs = ticker.C
+	}
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			err = s.cache.touch()
+		case <-resyncs:
+			err = s.sync(true)
+		case <-s.wake:
+			err = s.sync(false)
+		}
+		if err != nil && ctx.Err() == nil {
+			serveLogf("error: %v", err)
+		}
+	}
+}
+
+// listen serves handler on addr until ctx is done
+func listen(ctx context.Context, addr string, handler http.Handler, errs chan<- error) {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: serveHeaderTimeout, ReadTimeout: serveReadTimeout}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), interruptGrace)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	err := server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		errs <- fmt.Errorf("error serving on %v: %w", addr, err)
+	}
+}
+
+func doServe(env *cmdEnv, opts *serveOptions) error {
+	addresses := []string{}
+	for _, addr := range []string{opts.webhook, opts.ui, opts.metrics} {
+		if addr == "" {
+			continue
+		}
+		if contains(addresses, addr) {
+			return newError(errInvalidInput, "-webhook, -ui and -metrics need different addresses")
+		}
+		addresses = append(addresses, addr)
+	}
+	if len(addresses) == 0 {
+		return newError(errInvalidInput, "use -webhook, -ui or -metrics, like -webhook :8080")
+	}
+	if opts.refresh <= 0 {
+		return newError(errInvalidInput, "invalid -refresh %v", opts.refresh)
+	}
+	if opts.token == "" {
+		opts.token = os.Getenv("GHP_DASHBOARD_TOKEN")
+	}
+	if opts.write && opts.ui != "" && opts.token == "" && !loopbackAddress(opts.ui) {
+		return newError(errInvalidInput, "-write on %v lets anyone move cards, use -token or GHP_DASHBOARD_TOKEN, or serve -ui on localhost", opts.ui)
+	}
+	var s *webhookServer
+	if opts.webhook != "" {
+		if opts.secret == "" {
+			opts.secret = os.Getenv("GHP_WEBHOOK_SECRET")
+		}
+		if opts.secret == "" {
+			return newError(errInvalidInput, "missing webhook secret, use -secret or GHP_WEBHOOK_SECRET")
+		}
+		if env.client.board.path == "" {
+			return fmt.Errorf("no home directory for the board cache")
+		}
+		s = &webhookServer{env: env, cache: env.client.board, secret: []byte(opts.secret), wake: make(chan struct{}, 1)}
+		if opts.automate {
+			rules, err := loadAutomateRules(opts.rules)
+			if err != nil {
+				return err
+			}
+			s.rules = rules
+		}
+	}
+	err := checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	var d *dashboard
+	if opts.ui != "" || opts.metrics != "" {
+		d = &dashboard{env: env, writable: opts.write, token: opts.token, refresh: opts.refresh}
+	}
+
+	ctx := *env.client.context
+	errs := make(chan error, 3)
+	if s != nil {
+		if d != nil {
+			s.loaded = d.setBoard
+		}
+		s.cache.record = true
+		err = s.sync(true)
+		if err != nil {
+			return err
+		}
+		go s.loop(ctx, opts.resync)
+		go listen(ctx, opts.webhook, s, errs)
+		serveLogf("listening for %v webhooks on %v", strings.Join(webhookEvents, ", "), opts.webhook)
+	}
+	if d != nil && s == nil {
+		err = d.load()
+		if err != nil {
+			return err
+		}
+		go d.loop(ctx)
+	}
+	if opts.ui != "" {
+		go listen(ctx, opts.ui, d.handler(), errs)
+		mode := "read only"
+		if d.writable {
+			mode = "read write"
+		}
+		serveLogf("serving the %v dashboard on %v", mode, opts.ui)
+	}
+	if opts.metrics != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", d.metrics)
+		go listen(ctx, opts.metrics, mux, errs)
+		serveLogf("serving metrics on %v/metrics", opts.metrics)
+	}
+	select {
+	case err = <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func init() {
+	opts := &serveOptions{}
+	registerCommand(&ghpCommand{
+		name:    "serve",
+		summary: "Receive webhooks, serve a board dashboard and metrics",
+		description: `
+-webhook listens for github webhook deliveries of project_card, project_column, issues
+and pull_request events, checks their HMAC signature with -secret or
+$GHP_WEBHOOK_SECRET and refetches only what changed into ~/.ghp.board. While
+serve runs, other ghp commands read the board from there instead of asking
+github.
+
+Add an organization webhook pointing to the server, with content type
+application/json, the same secret and those events. -resync refetches
+everything now and then in case deliveries are lost and -automate runs the
+'ghp automate' rules after every change. Until the next resync the rules
+don't repeat a move nor send a card back to a column they took it out of, so
+they can't loop, while later moves like "approved" to Ready and then "closed"
+to Done still happen.
+
+-ui serves a web page of the board, with the filters and views of 'ghp list'
+in the query like /?filter=label:bug&view=mywork, and json at /api/columns and
+/api/cards?filter=. The board is reloaded every -refresh, from the board cache
+while a webhook server runs, or follows the deliveries with -webhook. -write
+allows moving cards from the page or with a json POST to /api/move like
+{"card": "api#12", "column": "Done"}. Unless -ui is a loopback address like
+localhost:8000, -write needs a -token or $GHP_DASHBOARD_TOKEN, sent as an
+Authorization: Bearer header to /api/move. The page asks for it once and keeps
+it in a cookie.
+
+-metrics serves prometheus metrics at /metrics: cards per column, state,
+assignee and label, the age of the oldest card of every column, wip limits
+and their breaches, and the api requests, cache hits and rate limit of ghp.`,
+		examples: []string{
+			"GHP_WEBHOOK_SECRET=s3cret ghp serve -webhook :8080",
+			"ghp serve -webhook 127.0.0.1:8080 -secret s3cret -automate -rules team-rules.json",
+			"ghp serve -ui localhost:8000 -refresh 30s",
+			"ghp serve -webhook :8080 -ui localhost:8000 -write",
+			"GHP_DASHBOARD_TOKEN=t0ken ghp serve -ui :8000 -write",
+			"ghp serve -webhook :8080 -metrics :9090",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			fs.StringVar(&opts.webhook, "webhook", "", "Address to receive webhooks on, like :8080")
+			fs.StringVar(&opts.secret, "secret", "", "Webhook secret, defaults to $GHP_WEBHOOK_SECRET")
+			fs.BoolVar(&opts.automate, "automate", false, "Run the automate rules after every change")
+			fs.StringVar(&opts.rules, "rules", "", "Automate rules file, defaults to ~/"+userAutomate)
+			fs.DurationVar(&opts.resync, "resync", time.Hour, "Refetch the whole board this often, 0 never")
+			fs.StringVar(&opts.ui, "ui", "", "Address to serve the dashboard on, like localhost:8000")
+			fs.BoolVar(&opts.write, "write", false, "Allow moving cards from the dashboard")
+			fs.StringVar(&opts.token, "token", "", "Token to move cards, defaults to $GHP_DASHBOARD_TOKEN")
+			fs.DurationVar(&opts.refresh, "refresh", defaultDashboardRefresh, "Reload the board of -ui and -metrics this often")
+			fs.StringVar(&opts.metrics, "metrics", "", "Address to serve prometheus metrics on, like :9090")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help serve'", strings.Join(args, " "))
+			}
+			return doServe(env, opts)
+		},
+	})
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/mitchellh/go-homedir"
+)
+
+const testSecret = "s3cret"
+
+// recordedDelivery reads a webhook payload from testdata, with the api urls
+// pointing to apiURL
+func recordedDelivery(t *testing.T, name, apiURL string) []byte {
+	t.Helper()
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(strings.ReplaceAll(string(body), "https://api.github.com", strings.TrimSuffix(apiURL, "/")))
+}
+
+func sign(newHash func() hash.Hash, prefix string, body []byte, secret string) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return prefix + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts a signed delivery to the server like github does
+func deliver(s http.Handler, event string, body []byte, secret string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", event)
+	r.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	r.Header.Set("X-Hub-Signature-256", sign(sha256.New, "sha256", body, secret))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// testHome keeps the files of the caches in a temporary home
+func testHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+	return home
+}
+
+// testWebhookServer is a server for project 7 of api.github.com with two
+// loaded columns and issue 3 and pull request 14 in the board cache
+func testWebhookServer(t *testing.T) *webhookServer {
+	testHome(t)
+	client := createClient(context.Background(), "x")
+	client.board.record = true
+	for _, url := range []string{"https://api.github.com/repos/org/api/issues/3", "https://api.github.com/repos/org/api/issues/14"} {
+		client.board.store(url, http.Header{}, []byte(`{}`))
+	}
+	return &webhookServer{
+		env:    &cmdEnv{state: &ghpConfig{DefaultProjectID: 7}, client: client},
+		cache:  client.board,
+		secret: []byte(testSecret),
+		wake:   make(chan struct{}, 1),
+		columns: map[int64]string{
+			1: "https://api.github.com/projects/columns/1/cards",
+			2: "https://api.github.com/projects/columns/2/cards",
+		},
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	body := recordedDelivery(t, "issues_labeled", "https://api.github.com")
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		body   []byte
+		valid  bool
+	}{
+		{"sha256", "X-Hub-Signature-256", sign(sha256.New, "sha256", body, testSecret), body, true},
+		{"sha1", "X-Hub-Signature", sign(sha1.New, "sha1", body, testSecret), body, true},
+		{"sha256 wrong secret", "X-Hub-Signature-256", sign(sha256.New, "sha256", body, "other"), body, false},
+		{"sha1 wrong secret", "X-Hub-Signature", sign(sha1.New, "sha1", body, "other"), body, false},
+		{"sha256 changed body", "X-Hub-Signature-256", sign(sha256.New, "sha256", body, testSecret), append([]byte(" "), body...), false},
+		{"sha1 in sha256 header", "X-Hub-Signature-256", sign(sha1.New, "sha256", body, testSecret), body, false},
+		{"missing", "", "", body, false},
+		{"no algorithm", "X-Hub-Signature-256", hex.EncodeToString([]byte("nope")), body, false},
+		{"not hex", "X-Hub-Signature-256", "sha256=zz", body, false},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.header != "" {
+			header.Set(test.header, test.value)
+		}
+		if valid := validSignature(header, test.body, []byte(testSecret)); valid != test.valid {
+			t.Errorf("%v: got valid %v, want %v", test.name, valid, test.valid)
+		}
+	}
+}
+
+func TestWebhookStale(t *testing.T) {
+	s := testWebhookServer(t)
+	lists := []string{"https://api.github.com/projects/columns/1/cards", "https://api.github.com/projects/columns/2/cards"}
+	tests := []struct {
+		event    string
+		delivery string
+		want     []string
+	}{
+		{"project_card", "project_card_moved", append(lists, "https://api.github.com/repos/org/api/issues/3")},
+		{"project_card", "project_card_note_created", lists},
+		{"project_card", "project_card_other_project", nil},
+		{"project_column", "project_column_created", append(lists, "https://api.github.com/projects/7/columns")},
+		{"project_column", "project_column_other_project", nil},
+		{"issues", "issues_labeled", []string{"https://api.github.com/repos/org/api/issues/3"}},
+		{"issues", "issues_other_repo", nil},
+		{"pull_request", "pull_request_synchronize", []string{"https://api.github.com/repos/org/api/issues/14", "https://api.github.com/repos/org/api/pulls/14"}},
+	}
+	for _, test := range tests {
+		payload, err := github.ParseWebHook(test.event, recordedDelivery(t, test.delivery, "https://api.github.com"))
+		if err != nil {
+			t.Fatalf("%v: %v", test.delivery, err)
+		}
+		got := s.stale(payload)
+		sort.Strings(got)
+		want := append([]string(nil), test.want...)
+		sort.Strings(want)
+		if len(got) == 0 && len(want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", test.delivery, got, want)
+		}
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	s := testWebhookServer(t)
+	body := recordedDelivery(t, "issues_labeled", "https://api.github.com")
+	tests := []struct {
+		name     string
+		event    string
+		delivery string
+		secret   string
+		status   int
+		pending  int
+	}{
+		{"bad signature", "issues", "issues_labeled", "other", http.StatusUnauthorized, 0},
+		{"ping", "ping", "ping", testSecret, http.StatusOK, 0},
+		{"unsubscribed event", "push", "ping", testSecret, http.StatusAccepted, 0},
+		{"not on the board", "issues", "issues_other_repo", testSecret, http.StatusAccepted, 0},
+		{"on the board", "issues", "issues_labeled", testSecret, http.StatusAccepted, 1},
+		{"card moved", "project_card", "project_card_moved", testSecret, http.StatusAccepted, 4},
+	}
+	for _, test := range tests {
+		w := deliver(s, test.event, recordedDelivery(t, test.delivery, "https://api.github.com"), test.secret)
+		if w.Code != test.status {
+			t.Errorf("%v: got status %v, want %v: %v", test.name, w.Code, test.status, w.Body.String())
+		}
+		s.mu.Lock()
+		pending := len(s.pending)
+		s.mu.Unlock()
+		if pending != test.pending {
+			t.Errorf("%v: got %v pending urls, want %v", test.name, pending, test.pending)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %v, want %v", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(string(body)))
+	r.Header.Set("X-GitHub-Event", "issues")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned: got status %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// fakeBoard is a github api with project 7, its columns with ids from 1 and
+// card 11 of issue 3 with the given labels, moves change the board
+type fakeBoard struct {
+	mu      sync.Mutex
+	url     string
+	names   []string
+	columns map[int64][]int64 // card ids by column id
+	labels  []string
+	moves   int
+}
+
+func (b *fakeBoard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var column int64
+	fmt.Sscanf(r.URL.Path, "/projects/columns/%d/cards", &column)
+	switch {
+	case r.URL.Path == "/projects/7/columns":
+		columns := []string{}
+		for n, name := range b.names {
+			columns = append(columns, fmt.Sprintf(`{"id":%[2]v,"name":%[3]q,"url":"%[1]v/projects/columns/%[2]v"}`, b.url, n+1, name))
+		}
+		fmt.Fprintf(w, "[%v]", strings.Join(columns, ","))
+	case column != 0:
+		cards := []string{}
+		for _, id := range b.columns[column] {
+			cards = append(cards, fmt.Sprintf(`{"id":%[2]v,"url":"%[1]v/projects/columns/cards/%[2]v","content_url":"%[1]v/repos/org/api/issues/3"}`, b.url, id))
+		}
+		fmt.Fprintf(w, "[%v]", strings.Join(cards, ","))
+	case r.URL.Path == "/repos/org/api/issues/3":
+		labels := []string{}
+		for _, label := range b.labels {
+			labels = append(labels, fmt.Sprintf(`{"name":%q}`, label))
+		}
+		fmt.Fprintf(w, `{"id":333,"number":3,"state":"open","url":"%[1]v/repos/org/api/issues/3","repository_url":"%[1]v/repos/org/api","labels":[%[2]v]}`,
+			b.url, strings.Join(labels, ","))
+	case r.URL.Path == "/repos/org/api":
+		fmt.Fprintf(w, `{"name":"api","url":"%v/repos/org/api","owner":{"login":"org"}}`, b.url)
+	case r.Method == "POST" && r.URL.Path == "/projects/columns/cards/11/moves":
+		move := struct {
+			ColumnID int64 `json:"column_id"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&move)
+		b.moves++
+		b.columns = map[int64][]int64{move.ColumnID: {11}}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not Found"}`)
+	}
+}
+
+func (b *fakeBoard) label(labels ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.labels = labels
+}
+
+func (b *fakeBoard) moveCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.moves
+}
+
+// testAutomationServer is a webhook server for the board running the rules
+func testAutomationServer(t *testing.T, board *fakeBoard, rules []automateRule) *webhookServer {
+	testHome(t)
+	srv := httptest.NewServer(board)
+	t.Cleanup(srv.Close)
+	board.url = srv.URL
+	client := createClient(context.Background(), "x")
+	client.apiClient.BaseURL, _ = url.Parse(srv.URL + "/")
+	client.board.record = true
+	return &webhookServer{
+		env:    &cmdEnv{state: &ghpConfig{DefaultProjectID: 7}, client: client, cache: initCache()},
+		cache:  client.board,
+		secret: []byte(testSecret),
+		wake:   make(chan struct{}, 1),
+		rules:  rules,
+	}
+}
+
+// deliverAndSync posts a recorded delivery of the fake board and syncs
+func deliverAndSync(t *testing.T, s *webhookServer, board *fakeBoard, event, name string) {
+	t.Helper()
+	w := deliver(s, event, recordedDelivery(t, name, board.url), testSecret)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("%v: got status %v: %v", name, w.Code, w.Body.String())
+	}
+	err := s.sync(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookAutomationDoesNotLoop(t *testing.T) {
+	board := &fakeBoard{names: []string{"Review", "Done"}, columns: map[int64][]int64{1: {11}}, labels: []string{"x"}}
+	s := testAutomationServer(t, board, []automateRule{
+		{When: "label:x", From: []string{"Review"}, Then: "move Done"},
+		{When: "label:x", Then: "move Review"},
+	})
+
+	err := s.sync(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.moveCount() != 1 {
+		t.Fatalf("got %v moves after loading, want 1", board.moveCount())
+	}
+	// the move comes back as a delivery, the second rule would send the
+	// card back to Review
+	for n := 0; n < 3; n++ {
+		deliverAndSync(t, s, board, "project_card", "project_card_moved")
+	}
+	if board.moveCount() != 1 {
+		t.Errorf("got %v moves after the deliveries, want 1", board.moveCount())
+	}
+	if !s.cache.has(board.url + "/projects/columns/2/cards") {
+		t.Errorf("the card lists aren't in the board cache")
+	}
+
+	// a resync lets the rules move the card again
+	err = s.sync(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.moveCount() != 2 {
+		t.Errorf("got %v moves after resyncing, want 2", board.moveCount())
+	}
+}
+
+func TestWebhookAutomationChains(t *testing.T) {
+	board := &fakeBoard{names: []string{"Review", "Ready", "Done"}, columns: map[int64][]int64{1: {11}}, labels: []string{"approved"}}
+	s := testAutomationServer(t, board, []automateRule{
+		{When: "label:approved", From: []string{"Review"}, Then: "move Ready"},
+		{When: "label:merged", Then: "move Done"},
+		{When: "label:approved", Then: "move Review"},
+	})
+
+	err := s.sync(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliverAndSync(t, s, board, "project_card", "project_card_moved")
+	if board.moveCount() != 1 {
+		t.Fatalf("got %v moves after approving, want 1", board.moveCount())
+	}
+	// a later rule moves the card on, the last one would send it back
+	board.label("approved", "merged")
+	deliverAndSync(t, s, board, "issues", "issues_labeled")
+	board.label("approved")
+	deliverAndSync(t, s, board, "issues", "issues_labeled")
+	if board.moveCount() != 2 {
+		t.Errorf("got %v moves, want 2", board.moveCount())
+	}
+	board.mu.Lock()
+	defer board.mu.Unlock()
+	if len(board.columns[3]) != 1 {
+		t.Errorf("the card isn't done: %v", board.columns)
+	}
+}
+
+func TestWebhookAutomationWithDashboardMoves(t *testing.T) {
+	board := &fakeBoard{names: []string{"Review", "Done"}, columns: map[int64][]int64{1: {11}}, labels: []string{"x"}}
+	s := testAutomationServer(t, board, []automateRule{{When: "label:x", From: []string{"Done"}, Then: "move Review"}})
+	d := &dashboard{env: s.env, writable: true}
+	// a card moved from the dashboard as soon as it gets the board, run with
+	// -race to see the rules don't read the columns meanwhile
+	var wg sync.WaitGroup
+	s.loaded = func(p *ProjectProxy) {
+		d.setBoard(p)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serveDashboard(d, "POST", "/api/move", `{"card": "api#3", "column": "Done"}`)
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %v: %v", w.Code, w.Body.String())
+			}
+		}()
+	}
+	err := s.sync(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if board.moveCount() != 1 {
+		t.Errorf("got %v moves, want 1", board.moveCount())
+	}
+}
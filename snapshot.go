@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const snapshotTimeFormat = "2006-01-02T150405"
+
+// snapshot is the full state of a project at some point, column order
+// includes empty columns and cards keep their column and position
+type snapshot struct {
+	Name      string      `json:"name"`
+	Project   string      `json:"project"`
+	ProjectID int64       `json:"project_id"`
+	Taken     time.Time   `json:"taken"`
+	Columns   []string    `json:"columns"`
+	Cards     []cardState `json:"cards"`
+}
+
+// newSnapshot records the state of a loaded project
+func newSnapshot(name string, state ghpConfig, p *ProjectProxy) *snapshot {
+	s := &snapshot{Name: name, Project: state.DefaultProject, ProjectID: state.DefaultProjectID, Taken: time.Now()}
+	for _, col := range p.columns {
+		s.Columns = append(s.Columns, col.name)
+	}
+	s.Cards = boardStates(p)
+	return s
+}
+
+func snapshotDir() (string, error) {
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, userSnapshots), nil
+}
+
+// snapshotPath returns the file of a snapshot by name, names with a path
+// separator or a .json extension are taken as files
+func snapshotPath(name string) (string, error) {
+	if strings.ContainsRune(name, os.PathSeparator) || strings.HasSuffix(name, ".json") {
+		return name, nil
+	}
+	dir, err := snapshotDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func (s *snapshot) save() error {
+	path, err := snapshotPath(s.Name)
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	return nil
+}
+
+func loadSnapshot(name string) (*snapshot, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, newError(errNotFound, "no snapshot named %v, see 'ghp snapshot ls'", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot %v: %w", name, err)
+	}
+	s := new(snapshot)
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, newError(errInvalidInput, "invalid snapshot %v: %v", name, err)
+	}
+	return s, nil
+}
+
+// snapshotNames returns the saved snapshots, oldest first
+func snapshotNames() []string {
+	dir, err := snapshotDir()
+	if err != nil {
+		return nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].ModTime().Before(files[b].ModTime())
+	})
+	names := []string{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(file.Name(), ".json"))
+		}
+	}
+	return names
+}
+
+// liveSnapshot loads the whole default project
+func liveSnapshot(state ghpConfig, cache *appCache, client *ghpClient, quiet bool) (*snapshot, error) {
+	err := checkAllConfig(&state, client)
+	if err != nil {
+		return nil, err
+	}
+	p, err := loadProject(state, cache, client, &listOptions{quiet: quiet})
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshot("live", state, p), nil
+}
+
+func doSnapshot(env *cmdEnv, args []string) error {
+	if len(args) == 0 {
+		return newError(errInvalidInput, "missing snapshot command, use save, ls or rm")
+	}
+	switch args[0] {
+	case "save":
+		name := time.Now().Format(snapshotTimeFormat)
+		if len(args) > 1 {
+			name = args[1]
+		}
+		s, err := liveSnapshot(*env.state, env.cache, env.client, env.opts.quiet)
+		if err != nil {
+			return err
+		}
+		s.Name = name
+		err = s.save()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved snapshot %v with %v cards in %v columns\n", name, len(s.Cards), len(s.Columns))
+	case "ls", "list":
+		for _, name := range snapshotNames() {
+			s, err := loadSnapshot(name)
+			if err != nil {
+				fmt.Printf("%v: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("%v: %v, %v cards, %v\n", name, s.Project, len(s.Cards), s.Taken.Format("2006-01-02 15:04"))
+		}
+	case "rm", "delete":
+		if len(args) < 2 {
+			return newError(errInvalidInput, "missing snapshot name")
+		}
+		path, err := snapshotPath(args[1])
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			return newError(errNotFound, "no snapshot named %v", args[1])
+		}
+		return err
+	default:
+		return newError(errInvalidInput, "unknown snapshot command %v, use save, ls or rm", args[0])
+	}
+	return nil
+}
+
+// changeDetail describes a change after the card title
+func changeDetail(c cardChange) string {
+	switch c.kind {
+	case changeMoved, changeRelabeled, changeReassigned:
+		return c.from + " → " + c.to
+	case changeRemoved:
+		return "from " + c.card.Column
+	}
+	return "in " + c.card.Column
+}
+
+// printChanges shows the changes grouped by kind
+func printChanges(changes []cardChange) {
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	summary := []string{}
+	for _, kind := range changeKinds {
+		lines := []string{}
+		for _, c := range changes {
+			if c.kind != kind {
+				continue
+			}
+			name := c.card.Ref
+			if c.card.Type != "note" {
+				name += " " + ellipseStr(c.card.Title, 40)
+			}
+			lines = append(lines, "  "+name+"  "+singleColorHub.styled(singleColorHub.theme.Muted, changeDetail(c)))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%v %v", len(lines), kind))
+		fmt.Printf("\n%v (%v):\n%v\n", singleColorHub.headerColorize(kind), len(lines), strings.Join(lines, "\n"))
+	}
+	fmt.Printf("\n%v\n", strings.Join(summary, ", "))
+}
+
+func doDiff(env *cmdEnv, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return newError(errInvalidInput, "use 'ghp diff <snapshot> [snapshot|live]'")
+	}
+	from, err := loadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	var to *snapshot
+	if len(args) == 1 || args[1] == "live" {
+		to, err = liveSnapshot(*env.state, env.cache, env.client, env.opts.quiet)
+	} else {
+		to, err = loadSnapshot(args[1])
+	}
+	if err != nil {
+		return err
+	}
+	if from.ProjectID != to.ProjectID {
+		return newError(errInvalidInput, "%v is from project %v and %v from %v", from.Name, from.Project, to.Name, to.Project)
+	}
+	if !env.opts.quiet {
+		fmt.Printf("Changes in %v from %v (%v) to %v (%v)\n", to.Project, from.Name, from.Taken.Format("2006-01-02 15:04"),
+			to.Name, to.Taken.Format("2006-01-02 15:04"))
+	}
+	printChanges(diffBoards(from.Cards, to.Cards))
+	return nil
+}
+
+func init() {
+	registerCommand(&ghpCommand{
+		name:    "snapshot",
+		args:    "save [name]|ls|rm <name>",
+		summary: "Save the state of the default project",
+		description: `
+Snapshots keep columns, card order and issue metadata in ~/.ghp.snapshots,
+named after the current time unless a name is given. Compare them with
+'ghp diff'.`,
+		examples: []string{
+			"ghp snapshot save standup",
+			"ghp snapshot ls",
+			"ghp snapshot rm standup",
+		},
+		complete: []string{"save ls rm", "@snapshots"},
+		run:      doSnapshot,
+	})
+	registerCommand(&ghpCommand{
+		name:    "diff",
+		args:    "<snapshot> [snapshot|live]",
+		summary: "Show the board changes between snapshots",
+		description: `
+Reports cards added, removed, moved between columns, reordered, closed,
+reopened, relabeled or reassigned. The second snapshot defaults to the live
+project. Snapshots can also be given as paths to their files.`,
+		examples: []string{
+			"ghp diff standup",
+			"ghp diff monday friday",
+		},
+		complete: []string{"@snapshots", "live @snapshots"},
+		run:      doDiff,
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// what counts as activity for -by
+var staleActivities = []string{"activity", "move"}
+
+const defaultStaleDays = 14
+
+// staleCard is a card without activity, updated is the last change of the
+// issue or note and moved when the card entered its column
+type staleCard struct {
+	card    card
+	column  *column
+	updated time.Time
+	moved   time.Time
+	idle    time.Duration
+}
+
+// staleOptions are the stale flags
+type staleOptions struct {
+	days    int
+	by      string
+	label   string
+	comment string
+	move    string
+	yes     bool
+}
+
+// lastMove returns when the card entered column, the card creation when the
+// timeline has no project events. A history not ending in the column misses
+// the last move, then the last card update is used, a move updates the card
+func lastMove(visits []columnVisit, column string, added, updated time.Time) time.Time {
+	if len(visits) == 0 {
+		return added
+	}
+	if last := visits[len(visits)-1]; last.column == column {
+		return last.since
+	}
+	if updated.After(added) {
+		return updated
+	}
+	return added
+}
+
+// findStale returns the listed cards idle for the given days, by column and
+// most idle first. Cards already in skip are left out
+func findStale(p *ProjectProxy, state ghpConfig, filters [][]string, opts *staleOptions, skip *column, now time.Time) ([]staleCard, error) {
+	stale := []staleCard{}
+	limit := time.Duration(opts.days) * 24 * time.Hour
+	for n := range p.columns {
+		col := &p.columns[n]
+		if col == skip {
+			continue
+		}
+		found := []staleCard{}
+		for _, c := range col.cards {
+			if !c.match(filters) {
+				continue
+			}
+			s := staleCard{card: c, column: col}
+			if i := cardIssue(c); i != nil {
+				events, err := p.getTimeline(i, &cacheUseOptions{})
+				if err != nil {
+					return nil, err
+				}
+				visits, _ := columnHistory(events, state.DefaultProjectID)
+				s.updated = i.ghIssue.GetUpdatedAt()
+				s.moved = lastMove(visits, col.name, i.createdAt.Time, i.updatedAt.Time)
+			} else if n, isNote := c.(*note); isNote {
+				s.updated, s.moved = n.updatedAt.Time, n.updatedAt.Time
+			}
+			last := s.moved
+			if opts.by == "activity" && s.updated.After(last) {
+				last = s.updated
+			}
+			s.idle = now.Sub(last)
+			if s.idle >= limit {
+				found = append(found, s)
+			}
+		}
+		sort.SliceStable(found, func(a, b int) bool {
+			return found[a].idle > found[b].idle
+		})
+		stale = append(stale, found...)
+	}
+	return stale, nil
+}
+
+func printStale(stale []staleCard) {
+	ref := func(c card) string {
+		if i := cardIssue(c); i != nil {
+			return i.ref()
+		}
+		return "note"
+	}
+	width := 0
+	for _, s := range stale {
+		width = max(width, len(ref(s.card)))
+	}
+	column := ""
+	for n, s := range stale {
+		if s.column.name != column {
+			column = s.column.name
+			count := 0
+			for _, other := range stale[n:] {
+				if other.column.name == column {
+					count++
+				}
+			}
+			fmt.Printf("\n%v (%v):\n", singleColorHub.headerColorize(column), count)
+		}
+		idle := fmt.Sprintf("idle %3vd", int(s.idle.Hours()/24))
+		detail := fmt.Sprintf("updated %v, in column since %v", s.updated.Format("Jan 2"), s.moved.Format("Jan 2"))
+		fmt.Printf("  %-*v %-40v %v  %v\n", width, ref(s.card), ellipseStr(cardTitle(s.card), 40),
+			singleColorHub.styled(singleColorHub.theme.Warn, idle), singleColorHub.styled(singleColorHub.theme.Muted, detail))
+	}
+}
+
+// applyStale labels, comments on and moves the stale cards, notes can only be
+// moved
+func applyStale(client *ghpClient, stale []staleCard, opts *staleOptions, target *column) error {
+	for _, s := range stale {
+		done := []string{}
+		if i := cardIssue(s.card); i != nil {
+			owner, repo, number := i.repository.GetOwner().GetLogin(), i.repository.GetName(), i.ghIssue.GetNumber()
+			if opts.label != "" {
+				err := client.addLabels(owner, repo, number, []string{opts.label})
+				if err != nil {
+					return err
+				}
+				done = append(done, "labeled "+opts.label)
+			}
+			if opts.comment != "" {
+				err := client.createComment(owner, repo, number, opts.comment)
+				if err != nil {
+					return err
+				}
+				done = append(done, "commented")
+			}
+		}
+		if target != nil {
+			id, err := cardID(s.card)
+			if err != nil {
+				return err
+			}
+			err = client.moveCard(id, "top", target.id)
+			if err != nil {
+				return err
+			}
+			done = append(done, "moved to "+target.name)
+		}
+		if len(done) != 0 {
+			fmt.Printf("%v: %v\n", cardName(s.card), strings.Join(done, ", "))
+		}
+	}
+	return nil
+}
+
+func doStale(env *cmdEnv, opts *staleOptions) error {
+	if opts.days < 1 {
+		return newError(errInvalidInput, "days must be at least 1")
+	}
+	if !contains(staleActivities, opts.by) {
+		return newError(errInvalidInput, "invalid -by %v, use one of %v", opts.by, strings.Join(staleActivities, ", "))
+	}
+	err := env.prepareList()
+	if err != nil {
+		return err
+	}
+	err = checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	state := *env.state
+	p, err := loadProject(state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	var target *column
+	if opts.move != "" {
+		target, err = findColumn(p, opts.move)
+		if err != nil {
+			return err
+		}
+	}
+	stale, err := findStale(p, state, env.filters.toFilters(state.User), opts, target, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Printf("No cards idle for %v days\n", opts.days)
+		return nil
+	}
+	if !env.opts.quiet {
+		fmt.Printf("Cards idle for %v days or more:\n", opts.days)
+	}
+	printStale(stale)
+
+	actions := []string{}
+	if opts.label != "" {
+		actions = append(actions, "label them "+opts.label)
+	}
+	if opts.comment != "" {
+		actions = append(actions, "comment on them")
+	}
+	if target != nil {
+		actions = append(actions, "move them to "+target.name)
+		work := 0
+		for _, s := range stale {
+			if cardIssue(s.card) != nil {
+				work++
+			}
+		}
+		if target.limit > 0 && target.wip()+work > target.limit {
+			return newError(errCheckFailed, "moving %v cards puts %v over its wip limit, %v/%v", work, target.name,
+				target.wip()+work, target.limit)
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	fmt.Println()
+	if !opts.yes && !askForConfirmation(fmt.Sprintf("%v, %v cards", strings.Join(actions, ", "), len(stale))) {
+		return newError(errInterrupted, "aborted, nothing changed")
+	}
+	return applyStale(env.client, stale, opts, target)
+}
+
+func init() {
+	opts := &staleOptions{}
+	registerCommand(&ghpCommand{
+		name:    "stale",
+		summary: "List cards without activity, label, comment or move them",
+		description: `
+A card is idle since its issue or pull request was last updated or since it
+entered its column, whichever is later, or only since it entered its column
+with -by move. Notes use their last update. List flags select the cards, like
+-exclude-column Done.
+
+-label, -comment and -move act on all the listed cards after confirmation,
+-yes skips it. Cards already in the -move column aren't listed.`,
+		examples: []string{
+			"ghp stale -days 30 -exclude-column Done",
+			"ghp stale -by move -column 'In progress'",
+			"ghp stale -days 60 -label stale -comment 'Still needed?' -move Icebox",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			fs.IntVar(&opts.days, "days", defaultStaleDays, "Days without activity")
+			fs.StringVar(&opts.by, "by", "activity", "Activity counted: "+strings.Join(staleActivities, " or "))
+			fs.StringVar(&opts.label, "label", "", "Add this label to the stale issues")
+			fs.StringVar(&opts.comment, "comment", "", "Comment on the stale issues")
+			fs.StringVar(&opts.move, "move", "", "Move the stale cards to this column")
+			fs.BoolVar(&opts.yes, "yes", false, "Don't ask for confirmation")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help stale'", strings.Join(args, " "))
+			}
+			return doStale(env, opts)
+		},
+	})
+}
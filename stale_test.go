@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func TestLastMove(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name    string
+		visits  []columnVisit
+		added   time.Time
+		updated time.Time
+		want    time.Time
+	}{
+		{"no events", nil, day(1), day(9), day(1)},
+		{"ends in the column", []columnVisit{{"To do", day(1)}, {"Review", day(4)}}, day(1), day(9), day(4)},
+		{"misses the last move", []columnVisit{{"To do", day(1)}}, day(1), day(6), day(6)},
+		{"misses the last move, not updated", []columnVisit{{"To do", day(2)}}, day(2), time.Time{}, day(2)},
+	}
+	for _, test := range tests {
+		if got := lastMove(test.visits, "Review", test.added, test.updated); !got.Equal(test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFindStale(t *testing.T) {
+	now := time.Date(2020, 3, 30, 0, 0, 0, 0, time.UTC)
+	ago := func(days int) *time.Time {
+		at := now.Add(-time.Duration(days) * 24 * time.Hour)
+		return &at
+	}
+	testHome(t)
+	p := &ProjectProxy{cache: initCache()}
+	// staleIssue entered Review moved days ago and was updated updated days ago
+	staleIssue := func(number, moved, updated int) *issue {
+		i := testIssue(number)
+		i.ghIssue.URL = github.String(fmt.Sprintf("https://api.github.com/repos/org/api/issues/%v", number))
+		i.ghIssue.UpdatedAt = ago(updated)
+		i.createdAt = github.Timestamp{Time: *ago(60)}
+		events := []*github.Timeline{{
+			Event:       github.String("moved_columns_in_project"),
+			CreatedAt:   ago(moved),
+			ProjectCard: &github.ProjectCard{ColumnName: github.String("Review")},
+		}}
+		p.cache.add(i.ghIssue.GetURL()+"/timeline?per_page=100", &events)
+		return i
+	}
+	p.columns = []column{
+		{name: "Review", cards: []card{
+			staleIssue(1, 20, 2),
+			staleIssue(2, 5, 30),
+			staleIssue(3, 30, 16),
+			&note{text: "plan", updatedAt: github.Timestamp{Time: *ago(15)}},
+		}},
+		{name: "Done", cards: []card{&note{text: "old", updatedAt: github.Timestamp{Time: *ago(40)}}}},
+	}
+
+	tests := []struct {
+		by   string
+		skip *column
+		want []string
+	}{
+		{"move", nil, []string{"api#3 30", "api#1 20", "note 15", "note 40"}},
+		{"activity", nil, []string{"api#3 16", "note 15", "note 40"}},
+		{"move", &p.columns[1], []string{"api#3 30", "api#1 20", "note 15"}},
+	}
+	for _, test := range tests {
+		stale, err := findStale(p, ghpConfig{}, nil, &staleOptions{days: 14, by: test.by}, test.skip, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, s := range stale {
+			ref := "note"
+			if i := cardIssue(s.card); i != nil {
+				ref = i.ref()
+			}
+			got = append(got, fmt.Sprintf("%v %v", ref, int(s.idle.Hours()/24)))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("-by %v: got %v, want %v", test.by, got, test.want)
+		}
+	}
+}
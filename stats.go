@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// stats formats for --format
+var statsFormats = []string{"table", "csv", "json"}
+
+const (
+	defaultStatsWeeks = 8
+	maxThroughputBar  = 40
+)
+
+// columnVisit is a card entering a column of the project, an empty column is
+// the card leaving the project
+type columnVisit struct {
+	column string
+	since  time.Time
+}
+
+// cardFlow is the way of a card through the board. Started is the first time
+// it reached the start column or a later one and done when it reached the
+// done column, or was closed
+type cardFlow struct {
+	Ref         string             `json:"ref"`
+	Title       string             `json:"title"`
+	Column      string             `json:"column"`
+	Created     time.Time          `json:"created"`
+	Started     *time.Time         `json:"started,omitempty"`
+	Done        *time.Time         `json:"done,omitempty"`
+	LeadHours   float64            `json:"lead_hours,omitempty"`
+	CycleHours  float64            `json:"cycle_hours,omitempty"`
+	AgeHours    float64            `json:"age_hours,omitempty"`
+	ColumnHours map[string]float64 `json:"column_hours"`
+}
+
+// flowSummary are the percentiles of a duration, in hours
+type flowSummary struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_hours"`
+	P85   float64 `json:"p85_hours"`
+	P95   float64 `json:"p95_hours"`
+	Mean  float64 `json:"mean_hours"`
+}
+
+type weekCount struct {
+	Week  string `json:"week"`
+	Count int    `json:"count"`
+}
+
+// flowStats are the metrics of the cards done since a date and the work in
+// progress
+type flowStats struct {
+	Project    string                 `json:"project"`
+	Start      string                 `json:"start_column"`
+	Done       string                 `json:"done_column"`
+	Since      time.Time              `json:"since"`
+	Cycle      flowSummary            `json:"cycle_time"`
+	Lead       flowSummary            `json:"lead_time"`
+	Columns    map[string]flowSummary `json:"time_in_column"`
+	Throughput []weekCount            `json:"weekly_throughput"`
+	Aging      []*cardFlow            `json:"aging_wip"`
+	Cards      []*cardFlow            `json:"cards"`
+	order      []string
+}
+
+// flowStages are the board columns in order and the ones starting and
+// ending the cycle
+type flowStages struct {
+	order []string
+	start string
+	done  string
+}
+
+// newFlowStages picks the start and done columns, by name or prefix, the
+// defaults are the first "progress" or "doing" column and the last column
+func newFlowStages(columns []string, start, done string) (flowStages, error) {
+	stages := flowStages{order: columns}
+	if len(columns) == 0 {
+		return stages, newError(errNotFound, "the project has no columns")
+	}
+	find := func(name string) string {
+		for _, col := range columns {
+			if matchColumn(col, []string{name}) {
+				return col
+			}
+		}
+		return ""
+	}
+	if start != "" {
+		stages.start = find(start)
+	} else {
+		for _, col := range columns {
+			lower := strings.ToLower(col)
+			if stages.start == "" && (strings.Contains(lower, "progress") || strings.Contains(lower, "doing")) {
+				stages.start = col
+			}
+		}
+		if stages.start == "" && len(columns) > 1 {
+			stages.start = columns[1]
+		} else if stages.start == "" {
+			stages.start = columns[0]
+		}
+	}
+	if done != "" {
+		stages.done = find(done)
+	} else {
+		stages.done = columns[len(columns)-1]
+	}
+	if stages.start == "" {
+		return stages, newError(errInvalidInput, "no column %v, the columns are %v", start, strings.Join(columns, ", "))
+	}
+	if stages.done == "" {
+		return stages, newError(errInvalidInput, "no column %v, the columns are %v", done, strings.Join(columns, ", "))
+	}
+	if stages.rank(stages.start) > stages.rank(stages.done) {
+		return stages, newError(errInvalidInput, "the start column %v is after the done column %v", stages.start, stages.done)
+	}
+	return stages, nil
+}
+
+// rank is the position of a column in the board, -1 for unknown columns
+func (s flowStages) rank(column string) int {
+	for n, col := range s.order {
+		if col == column {
+			return n
+		}
+	}
+	return -1
+}
+
+// columnHistory returns the columns a card went through in the project and
+// when it was closed, zero when open
+func columnHistory(events []*github.Timeline, projectID int64) ([]columnVisit, time.Time) {
+	visits := []columnVisit{}
+	var closed time.Time
+	for _, event := range events {
+		card := event.ProjectCard
+		inProject := card != nil && (card.GetProjectID() == 0 || card.GetProjectID() == projectID)
+		switch event.GetEvent() {
+		case "added_to_project", "moved_columns_in_project", "converted_note_to_issue":
+			if inProject {
+				visits = append(visits, columnVisit{card.GetColumnName(), event.GetCreatedAt()})
+			}
+		case "removed_from_project":
+			if inProject {
+				visits = append(visits, columnVisit{"", event.GetCreatedAt()})
+			}
+		case "closed":
+			closed = event.GetCreatedAt()
+		case "reopened":
+			closed = time.Time{}
+		}
+	}
+	sort.SliceStable(visits, func(a, b int) bool {
+		return visits[a].since.Before(visits[b].since)
+	})
+	return visits, closed
+}
+
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*10) / 10
+}
+
+// newCardFlow computes the flow of a card in column from its history
+func newCardFlow(i *issue, column string, visits []columnVisit, closed time.Time, stages flowStages, now time.Time) *cardFlow {
+	f := &cardFlow{Ref: i.ref(), Title: i.ghIssue.GetTitle(), Column: column, Created: i.ghIssue.GetCreatedAt(),
+		ColumnHours: make(map[string]float64)}
+	startRank := stages.rank(stages.start)
+	for n, visit := range visits {
+		if f.Started == nil && stages.rank(visit.column) >= startRank {
+			since := visit.since
+			f.Started = &since
+		}
+		if visit.column == stages.done && column == stages.done {
+			since := visit.since
+			f.Done = &since
+		}
+		if visit.column == "" || visit.column == stages.done {
+			continue
+		}
+		until := now
+		if n+1 < len(visits) {
+			until = visits[n+1].since
+		}
+		f.ColumnHours[visit.column] += hours(until.Sub(visit.since))
+	}
+	if f.Done == nil && !closed.IsZero() {
+		f.Done = &closed
+	}
+	if f.Done != nil {
+		f.LeadHours = hours(f.Done.Sub(f.Created))
+		if f.Started != nil && !f.Started.After(*f.Done) {
+			f.CycleHours = hours(f.Done.Sub(*f.Started))
+		}
+	} else if f.Started != nil {
+		f.AgeHours = hours(now.Sub(*f.Started))
+	}
+	return f
+}
+
+// summarize returns the percentiles of values in hours
+func summarize(values []float64) flowSummary {
+	s := flowSummary{Count: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return sorted[rank]
+	}
+	s.P50, s.P85, s.P95 = percentile(50), percentile(85), percentile(95)
+	total := 0.0
+	for _, v := range sorted {
+		total += v
+	}
+	s.Mean = math.Round(total/float64(len(sorted))*10) / 10
+	return s
+}
+
+// weekStart returns the monday starting the week of t
+func weekStart(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// computeStats aggregates the flows of the cards done in the last weeks and
+// the cards in progress
+func computeStats(project string, flows []*cardFlow, stages flowStages, weeks int, now time.Time) *flowStats {
+	first := weekStart(now).AddDate(0, 0, -7*(weeks-1))
+	stats := &flowStats{Project: project, Start: stages.start, Done: stages.done, Since: first,
+		Columns: make(map[string]flowSummary), Cards: flows, order: stages.order}
+	counts := make([]int, weeks)
+	cycle, lead := []float64{}, []float64{}
+	inColumn := make(map[string][]float64)
+	for _, f := range flows {
+		if f.Done == nil {
+			if f.Started != nil {
+				stats.Aging = append(stats.Aging, f)
+			}
+			continue
+		}
+		week := int(math.Round(weekStart(*f.Done).Sub(first).Hours() / 24 / 7))
+		if week < 0 || week >= weeks {
+			continue
+		}
+		counts[week]++
+		lead = append(lead, f.LeadHours)
+		if f.Started != nil {
+			cycle = append(cycle, f.CycleHours)
+		}
+		for col, h := range f.ColumnHours {
+			inColumn[col] = append(inColumn[col], h)
+		}
+	}
+	stats.Cycle, stats.Lead = summarize(cycle), summarize(lead)
+	for col, values := range inColumn {
+		stats.Columns[col] = summarize(values)
+	}
+	for n, count := range counts {
+		stats.Throughput = append(stats.Throughput, weekCount{first.AddDate(0, 0, 7*n).Format("2006-01-02"), count})
+	}
+	sort.SliceStable(stats.Aging, func(a, b int) bool {
+		return stats.Aging[a].AgeHours > stats.Aging[b].AgeHours
+	})
+	return stats
+}
+
+// shortDuration formats hours as "3d 4h", "5h 10m" or "12m"
+func shortDuration(h float64) string {
+	d := time.Duration(h * float64(time.Hour)).Round(time.Minute)
+	days := int(d.Hours()) / 24
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%vd %vh", days, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%vh %vm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%vm", int(d.Minutes()))
+}
+
+func writeStatsTable(w io.Writer, s *flowStats) {
+	header := singleColorHub.headerColorize
+	muted := func(str string) string {
+		return singleColorHub.styled(singleColorHub.theme.Muted, str)
+	}
+	row := func(name string, sum flowSummary) {
+		if sum.Count == 0 {
+			fmt.Fprintf(w, "  %-20v %5v\n", ellipseStr(name, 20), 0)
+			return
+		}
+		fmt.Fprintf(w, "  %-20v %5v %9v %9v %9v %9v\n", ellipseStr(name, 20), sum.Count,
+			shortDuration(sum.P50), shortDuration(sum.P85), shortDuration(sum.P95), shortDuration(sum.Mean))
+	}
+	fmt.Fprintf(w, "%v\n", header(fmt.Sprintf("Flow of %v since %v", s.Project, s.Since.Format("2006-01-02"))))
+	fmt.Fprintf(w, "%v\n\n", muted(fmt.Sprintf("cycle from %v to %v", s.Start, s.Done)))
+	fmt.Fprintf(w, "  %-20v %5v %9v %9v %9v %9v\n", "", "cards", "p50", "p85", "p95", "mean")
+	row("cycle time", s.Cycle)
+	row("lead time", s.Lead)
+
+	fmt.Fprintf(w, "\n%v\n", header("Time in column"))
+	for _, col := range s.order {
+		if sum, exists := s.Columns[col]; exists {
+			row(col, sum)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%v\n", header("Weekly throughput"))
+	most := 1
+	for _, week := range s.Throughput {
+		most = max(most, week.Count)
+	}
+	for _, week := range s.Throughput {
+		bar := strings.Repeat("█", week.Count*maxThroughputBar/most)
+		fmt.Fprintf(w, "  %v %3v %v\n", week.Week, week.Count, singleColorHub.styled(singleColorHub.theme.Ok, bar))
+	}
+
+	fmt.Fprintf(w, "\n%v\n", header(fmt.Sprintf("Aging work in progress (%v)", len(s.Aging))))
+	for _, f := range s.Aging {
+		age := shortDuration(f.AgeHours)
+		if s.Cycle.Count != 0 && f.AgeHours > s.Cycle.P85 {
+			age = singleColorHub.styled(singleColorHub.theme.Warn, age+" > p85")
+		}
+		fmt.Fprintf(w, "  %-12v %-16v %v  %v\n", f.Ref, ellipseStr(f.Column, 16), ellipseStr(f.Title, 40), age)
+	}
+}
+
+// writeStatsCSV writes a row per card with its times in hours
+func writeStatsCSV(w io.Writer, s *flowStats) error {
+	out := csv.NewWriter(w)
+	header := []string{"ref", "title", "column", "created", "started", "done", "lead_hours", "cycle_hours", "age_hours"}
+	columns := []string{}
+	for _, col := range s.order {
+		if col != s.Done {
+			columns = append(columns, col)
+			header = append(header, "hours_in "+col)
+		}
+	}
+	err := out.Write(header)
+	if err != nil {
+		return err
+	}
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	number := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, f := range s.Cards {
+		record := []string{f.Ref, f.Title, f.Column, f.Created.Format(time.RFC3339), optionalTime(f.Started),
+			optionalTime(f.Done), number(f.LeadHours), number(f.CycleHours), number(f.AgeHours)}
+		for _, col := range columns {
+			record = append(record, number(f.ColumnHours[col]))
+		}
+		err = out.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// cardHistory is a listed card with the columns it went through
+type cardHistory struct {
+	issue  *issue
+	column string
+	visits []columnVisit
+	closed time.Time
+}
+
+// loadHistories loads the project and the timelines of the issue and pull
+// request cards matching the list flags. Start and done columns default to
+// the config ones
+func loadHistories(env *cmdEnv, start, done string) (flowStages, []cardHistory, error) {
+	err := env.prepareList()
+	if err != nil {
+		return flowStages{}, nil, err
+	}
+	err = checkAllConfig(env.state, env.client)
+	if err != nil {
+		return flowStages{}, nil, err
+	}
+	state := *env.state
+	p, err := loadProject(state, env.cache, env.client, env.opts)
+	if err != nil {
+		return flowStages{}, nil, err
+	}
+	columns := []string{}
+	for _, col := range p.columns {
+		columns = append(columns, col.name)
+	}
+	if start == "" {
+		start = state.CycleStartColumn
+	}
+	if done == "" {
+		done = state.CycleDoneColumn
+	}
+	stages, err := newFlowStages(columns, start, done)
+	if err != nil {
+		return stages, nil, err
+	}
+
+	filters := env.filters.toFilters(state.User)
+	histories := []cardHistory{}
+	for _, col := range p.columns {
+		for _, c := range col.cards {
+			i := cardIssue(c)
+			if i == nil || !c.match(filters) {
+				continue
+			}
+			events, err := p.getTimeline(i, &cacheUseOptions{})
+			if err != nil {
+				return stages, nil, err
+			}
+			visits, closed := columnHistory(events, state.DefaultProjectID)
+			if len(visits) == 0 {
+				// without project events all we know is when the card was added
+				visits = []columnVisit{{col.name, i.createdAt.Time}}
+			}
+			histories = append(histories, cardHistory{i, col.name, visits, closed})
+		}
+	}
+	return stages, histories, nil
+}
+
+// statsOptions are the stats flags
+type statsOptions struct {
+	start  string
+	done   string
+	weeks  int
+	format string
+}
+
+func doStats(env *cmdEnv, opts *statsOptions) error {
+	if !contains(statsFormats, opts.format) {
+		return newError(errInvalidInput, "invalid format %v, use one of %v", opts.format, strings.Join(statsFormats, ", "))
+	}
+	if opts.weeks < 1 {
+		return newError(errInvalidInput, "weeks must be at least 1")
+	}
+	stages, histories, err := loadHistories(env, opts.start, opts.done)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	flows := []*cardFlow{}
+	for _, h := range histories {
+		flows = append(flows, newCardFlow(h.issue, h.column, h.visits, h.closed, stages, now))
+	}
+	stats := computeStats(env.state.DefaultProject, flows, stages, opts.weeks, now)
+
+	switch opts.format {
+	case "csv":
+		return writeStatsCSV(os.Stdout, stats)
+	case "json":
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		writeStatsTable(os.Stdout, stats)
+	}
+	return nil
+}
+
+func init() {
+	opts := &statsOptions{}
+	registerCommand(&ghpCommand{
+		name:    "stats",
+		summary: "Show cycle time, lead time, throughput and aging work",
+		description: `
+Reads the project events of every card timeline to know when cards moved
+between columns. Cycle time goes from the start column, or a later one, to
+the done column or the issue being closed, lead time from the issue creation.
+Only cards done in the last -weeks count for times and throughput, aging work
+are the started cards not done yet, marked when older than the p85 cycle time.
+
+The start column defaults to cycle_start_column in config, or the first
+column named like "in progress" or "doing", the done column to
+cycle_done_column or the last column. List flags select the cards.`,
+		examples: []string{
+			"ghp stats",
+			"ghp stats -start Doing -done Deployed -weeks 12",
+			"ghp stats -filter repo:api -format csv > flow.csv",
+		},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			listFlags(fs, env)
+			fs.StringVar(&opts.start, "start", "", "Column starting the cycle, by name or prefix")
+			fs.StringVar(&opts.done, "done", "", "Column ending the cycle, by name or prefix")
+			fs.IntVar(&opts.weeks, "weeks", defaultStatsWeeks, "Weeks of done cards to measure")
+			fs.StringVar(&opts.format, "format", "table", "Output format: "+strings.Join(statsFormats, ", "))
+		},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 0 {
+				return newError(errInvalidInput, "unexpected arguments %v, see 'ghp help stats'", strings.Join(args, " "))
+			}
+			return doStats(env, opts)
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   flowSummary
+	}{
+		{"empty", nil, flowSummary{}},
+		{"one", []float64{7}, flowSummary{Count: 1, P50: 7, P85: 7, P95: 7, Mean: 7}},
+		{"unsorted", []float64{4, 1, 3, 2}, flowSummary{Count: 4, P50: 2, P85: 4, P95: 4, Mean: 2.5}},
+		{
+			"ten",
+			[]float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			flowSummary{Count: 10, P50: 5, P85: 9, P95: 10, Mean: 5.5},
+		},
+		{
+			"twenty",
+			[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+			flowSummary{Count: 20, P50: 10, P85: 17, P95: 19, Mean: 10.5},
+		},
+		{"rounded mean", []float64{1, 1, 2}, flowSummary{Count: 3, P50: 1, P85: 2, P95: 2, Mean: 1.3}},
+	}
+	for _, test := range tests {
+		if got := summarize(test.values); got != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSummarizeKeepsValues(t *testing.T) {
+	values := []float64{3, 1, 2}
+	summarize(values)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("summarize sorted its argument: %v", values)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// theme styles are space separated words: "bold", "dim", "italic",
+// "underline", a "#rrggbb" color, "hashed" for a stable color derived from the
+// text, or "none". Empty fields in user themes are taken from the base theme
+type theme struct {
+	Base      string `json:"base,omitempty"`
+	Header    string `json:"header,omitempty"`
+	Repo      string `json:"repo,omitempty"`
+	Assignee  string `json:"assignee,omitempty"`
+	Note      string `json:"note,omitempty"`
+	Closed    string `json:"closed,omitempty"`
+	Milestone string `json:"milestone,omitempty"`
+	Linked    string `json:"linked,omitempty"`
+	Ok        string `json:"ok,omitempty"`
+	Fail      string `json:"fail,omitempty"`
+	Warn      string `json:"warn,omitempty"`
+	Muted     string `json:"muted,omitempty"`
+	// Labels is "github" for real label colors, "hashed" or "none"
+	Labels string `json:"labels,omitempty"`
+	// Hashed adjusts hashed colors: "bright" for dark backgrounds, "dark" for
+	// light backgrounds or "none" to leave them as generated
+	Hashed string `json:"hashed,omitempty"`
+}
+
+var builtinThemes = map[string]theme{
+	"dark": {
+		Header:    "bold",
+		Repo:      "hashed",
+		Assignee:  "hashed",
+		Note:      "none",
+		Closed:    "dim",
+		Milestone: "#d670d6",
+		Linked:    "#29b8db",
+		Ok:        "#23d18b",
+		Fail:      "#f14c4c",
+		Warn:      "#f5f543",
+		Muted:     "#808080",
+		Labels:    "github",
+		Hashed:    "bright",
+	},
+	"light": {
+		Header:    "bold",
+		Repo:      "hashed",
+		Assignee:  "hashed",
+		Note:      "#555555",
+		Closed:    "#999999",
+		Milestone: "#8b008b",
+		Linked:    "#005f87",
+		Ok:        "#008700",
+		Fail:      "#c00000",
+		Warn:      "#af5f00",
+		Muted:     "#8a8a8a",
+		Labels:    "github",
+		Hashed:    "dark",
+	},
+	"high-contrast": {
+		Header:    "bold underline #ffffff",
+		Repo:      "bold #ffff00",
+		Assignee:  "bold #00ffff",
+		Note:      "#ffffff",
+		Closed:    "#ff5fff",
+		Milestone: "#ff87ff",
+		Linked:    "#5fffff",
+		Ok:        "bold #00ff00",
+		Fail:      "bold #ff0000",
+		Warn:      "bold #ffff00",
+		Muted:     "#d0d0d0",
+		Labels:    "github",
+		Hashed:    "none",
+	},
+	"monochrome": {
+		Header:    "bold underline",
+		Repo:      "bold",
+		Assignee:  "none",
+		Note:      "italic",
+		Closed:    "dim",
+		Milestone: "none",
+		Linked:    "none",
+		Ok:        "none",
+		Fail:      "bold",
+		Warn:      "none",
+		Muted:     "dim",
+		Labels:    "none",
+		Hashed:    "none",
+	},
+}
+
+const defaultTheme = "dark"
+
+// color modes for --color
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// resolveTheme finds a builtin or user defined theme, user themes override
+// builtin ones with the same name
+func resolveTheme(name string, userThemes map[string]theme) (theme, error) {
+	if name == "" {
+		name = defaultTheme
+	}
+	custom, isCustom := userThemes[name]
+	if !isCustom {
+		builtin, exists := builtinThemes[name]
+		if !exists {
+			return builtinThemes[defaultTheme], newError(errInvalidInput, "unknown theme %v", name)
+		}
+		return builtin, nil
+	}
+	baseName := custom.Base
+	if baseName == "" || baseName == name {
+		baseName = defaultTheme
+	}
+	base, exists := builtinThemes[baseName]
+	if !exists {
+		return builtinThemes[defaultTheme], newError(errInvalidInput, "unknown base theme %v for %v", baseName, name)
+	}
+	return base.overlay(custom), nil
+}
+
+// overlay returns t with the non empty fields of o
+func (t theme) overlay(o theme) theme {
+	pick := func(a, b string) string {
+		if b != "" {
+			return b
+		}
+		return a
+	}
+	return theme{
+		Header:    pick(t.Header, o.Header),
+		Repo:      pick(t.Repo, o.Repo),
+		Assignee:  pick(t.Assignee, o.Assignee),
+		Note:      pick(t.Note, o.Note),
+		Closed:    pick(t.Closed, o.Closed),
+		Milestone: pick(t.Milestone, o.Milestone),
+		Linked:    pick(t.Linked, o.Linked),
+		Ok:        pick(t.Ok, o.Ok),
+		Fail:      pick(t.Fail, o.Fail),
+		Warn:      pick(t.Warn, o.Warn),
+		Muted:     pick(t.Muted, o.Muted),
+		Labels:    pick(t.Labels, o.Labels),
+		Hashed:    pick(t.Hashed, o.Hashed),
+	}
+}
+
+// setupColor decides if output is colored: "never" and NO_COLOR disable
+// color, "always" forces it even when piped, "auto" needs a terminal
+func setupColor(mode string) error {
+	switch mode {
+	case colorAlways:
+		color.ForceOpenColor()
+		color.Enable = true
+	case colorNever:
+		color.Enable = false
+	case colorAuto, "":
+		_, noColor := os.LookupEnv("NO_COLOR")
+		color.Enable = !noColor && isTerminal(os.Stdout) && color.SupportColor()
+	default:
+		return newError(errInvalidInput, "invalid color mode %v, use auto, always or never", mode)
+	}
+	return nil
+}
+
+// styleCode converts a theme style to an escape code, hashed colors are
+// resolved with seed
+func (c *colorHub) styleCode(style, seed string) string {
+	codes := []string{}
+	for _, word := range strings.Fields(style) {
+		switch word {
+		case "bold":
+			codes = append(codes, color.OpBold.String())
+		case "dim":
+			codes = append(codes, color.OpFuzzy.String())
+		case "italic":
+			codes = append(codes, color.OpItalic.String())
+		case "underline":
+			codes = append(codes, color.OpUnderscore.String())
+		case "hashed":
+			codes = append(codes, c.code(c.seedColor(seed)))
+		default:
+			rgb, valid := parseHexColor(strings.TrimPrefix(word, "#"))
+			if valid {
+				codes = append(codes, c.code(color.RGB(rgb[0], rgb[1], rgb[2])))
+			}
+		}
+	}
+	return strings.Join(codes, ";")
+}
+
+// styled renders str with a theme style
+func (c *colorHub) styled(style, str string) string {
+	if str == "" {
+		return str
+	}
+	return color.RenderCode(c.styleCode(style, str), str)
+}
+
+// adjustHashed moves hashed colors away from the background luminance
+func adjustHashed(col color.RGBColor, mode string) color.RGBColor {
+	rgb := [3]uint8{col[0], col[1], col[2]}
+	l := luminance(rgb)
+	switch mode {
+	case "bright":
+		if l < 0.45 {
+			return mixColor(rgb, [3]uint8{255, 255, 255}, (0.45-l)/(1-l))
+		}
+	case "dark":
+		if l > 0.5 {
+			return mixColor(rgb, [3]uint8{0, 0, 0}, (l-0.5)/l)
+		}
+	}
+	return col
+}
+
+// mixColor blends a with b, ratio is the weight of b
+func mixColor(a, b [3]uint8, ratio float64) color.RGBColor {
+	mixed := [3]uint8{}
+	for n := range a {
+		mixed[n] = uint8(float64(a[n])*(1-ratio) + float64(b[n])*ratio)
+	}
+	return color.RGB(mixed[0], mixed[1], mixed[2])
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRetries       = 5
+	baseBackoff      = time.Second
+	maxBackoff       = 2 * time.Minute
+	maxQuotaWait     = time.Minute
+	lowQuotaFraction = 0.1
+)
+
+// rateLimitTransport tracks the github quota from X-RateLimit-* headers and
+// retries secondary rate limits and transient errors with jittered
+// exponential backoff
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+	warned    bool
+	requests  int
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{base: base, limit: -1, remaining: -1}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("can't retry request to %v without a replayable body", req.URL)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		t.mu.Lock()
+		t.requests++
+		t.mu.Unlock()
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			if !idempotent(req) || attempt >= maxRetries || req.Context().Err() != nil {
+				return nil, err
+			}
+			err = t.wait(req, backoff(attempt))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.track(resp)
+		wait, retry := t.retryAfter(req, resp, attempt)
+		if !retry || attempt >= maxRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+		err = t.wait(req, wait)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait sleeps d or until the request is cancelled
+func (t *rateLimitTransport) wait(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// track updates the known quota and warns once when it gets low
+func (t *rateLimitTransport) track(resp *http.Response) {
+	limit, errLimit := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, errReset := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if errLimit != nil || errRemaining != nil || errReset != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit = limit
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	if !t.warned && float64(remaining) < float64(limit)*lowQuotaFraction {
+		t.warned = true
+		fmt.Fprintf(os.Stderr, "warning: github API quota is low, %v of %v requests left until %v\n",
+			remaining, limit, t.reset.Format("15:04:05"))
+	}
+}
+
+// idempotent is true for the requests that can be sent again after a
+// network or server error, github may have applied a failed write already
+func idempotent(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == ""
+}
+
+// retryAfter decides if a response should be retried and how long to wait.
+// Rate limited requests weren't applied and are retried whatever the method,
+// server errors only for idempotent requests
+func (t *rateLimitTransport) retryAfter(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait := time.Duration(max(seconds, 0)) * time.Second
+			return wait, wait <= maxQuotaWait
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			// primary quota exhausted, only wait if the reset is close
+			wait := time.Until(t.quotaReset()) + time.Second
+			return wait, wait > 0 && wait <= maxQuotaWait
+		}
+		if resp.StatusCode == http.StatusForbidden && !mentionsRateLimit(resp) {
+			return 0, false
+		}
+		return backoff(attempt), true
+	case resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout,
+		resp.StatusCode == http.StatusInternalServerError:
+		return backoff(attempt), idempotent(req)
+	}
+	return 0, false
+}
+
+// mentionsRateLimit checks the body of a 403 for secondary rate limit
+// messages, the body is restored for the caller
+func mentionsRateLimit(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "rate limit")
+}
+
+// backoff returns an exponential delay for attempt with half of it jittered
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << uint(attempt)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+func (t *rateLimitTransport) quotaReset() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reset
+}
+
+// quota returns the last known limit, remaining requests and reset time,
+// limit is -1 before any api response
+func (t *rateLimitTransport) quota() (int, int, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit, t.remaining, t.reset
+}
+
+// etagTransport makes GET requests conditional with the ETag of the last
+// response for the same url, a 304 is answered with the stored body. Github
+// doesn't count 304 responses against the quota, so refreshing a board only
+// pays for what changed
+type etagTransport struct {
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	responses map[string]*etagResponse
+}
+
+type etagResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+func newETagTransport(base http.RoundTripper) *etagTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &etagTransport{base: base, responses: make(map[string]*etagResponse)}
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	t.mu.Lock()
+	stored := t.responses[key]
+	t.mu.Unlock()
+	if stored != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", stored.etag)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && stored != nil {
+		resp.Body.Close()
+		header := stored.header.Clone()
+		for name, values := range resp.Header {
+			header[name] = values
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header = header
+		resp.Body = ioutil.NopCloser(bytes.NewReader(stored.body))
+		resp.ContentLength = int64(len(stored.body))
+		return resp, nil
+	}
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	t.mu.Lock()
+	t.responses[key] = &etagResponse{etag: etag, header: resp.Header.Clone(), body: body}
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// requestCount returns the requests sent to github, retries included
+func (t *rateLimitTransport) requestCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
@@ -16,19 +16,19 @@ func choice(prompt string, choices []string) (int, error) {
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
-		return 0, fmt.Errorf("error reading choice")
+		return 0, newError(errInvalidInput, "error reading choice")
 	}
 	response = strings.ToLower(strings.TrimSpace(response))
 	if response == "exit" {
-		return 0, fmt.Errorf("aborted by user")
+		return 0, newError(errInterrupted, "aborted by user")
 	}
 
 	index, err := strconv.Atoi(response)
 	if err != nil {
-		return 0, fmt.Errorf("invalid input: %v", err)
+		return 0, newError(errInvalidInput, "invalid input: %v", err)
 	}
 	if index == 0 || index > len(choices) {
-		return 0, fmt.Errorf("invalid input: %v", index)
+		return 0, newError(errInvalidInput, "invalid input: %v", index)
 	}
 	return index - 1, nil
 }
@@ -52,3 +52,18 @@ func askForConfirmation(s string) bool {
 		return false
 	}
 }
+
+// lastPathElement returns the last element of an url or path
+func lastPathElement(url string) string {
+	parts := strings.Split(strings.TrimRight(url, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+func contains(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// savedView is a named set of list options stored in config
+type savedView struct {
+	Filters        []string `json:"filters,omitempty"`
+	Columns        []string `json:"columns,omitempty"`
+	ExcludeColumns []string `json:"exclude_columns,omitempty"`
+	Sort           string   `json:"sort,omitempty"`
+	GroupBy        string   `json:"group_by,omitempty"`
+}
+
+func (v *savedView) String() string {
+	parts := []string{}
+	if len(v.Filters) != 0 {
+		f := filterFlags(v.Filters)
+		parts = append(parts, "filter: "+f.String())
+	}
+	if len(v.Columns) != 0 {
+		parts = append(parts, "columns: "+strings.Join(v.Columns, ", "))
+	}
+	if len(v.ExcludeColumns) != 0 {
+		parts = append(parts, "excluded columns: "+strings.Join(v.ExcludeColumns, ", "))
+	}
+	if v.Sort != "" {
+		parts = append(parts, "sort: "+v.Sort)
+	}
+	if v.GroupBy != "" {
+		parts = append(parts, "group by: "+v.GroupBy)
+	}
+	if len(parts) == 0 {
+		return "(everything)"
+	}
+	return strings.Join(parts, "\n")
+}
+
+// applyView sets list options from a view, options given in the command
+// line take precedence
+func applyView(v savedView, filters *filterFlags, opts *listOptions) {
+	if len(*filters) == 0 {
+		*filters = append(*filters, v.Filters...)
+	}
+	if len(opts.columns) == 0 {
+		opts.columns = append(opts.columns, v.Columns...)
+	}
+	if len(opts.excludeColumns) == 0 {
+		opts.excludeColumns = append(opts.excludeColumns, v.ExcludeColumns...)
+	}
+	if opts.sortBy == "" {
+		opts.sortBy = v.Sort
+	}
+	if opts.groupBy == "" {
+		opts.groupBy = v.GroupBy
+	}
+}
+
+func viewNames(state *ghpConfig) []string {
+	names := make([]string, 0, len(state.Views))
+	for name := range state.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// doView handles "ghp view save|ls|rm|show"
+func doView(state *ghpConfig, args []string, filters filterFlags, opts *listOptions) error {
+	if len(args) == 0 {
+		return newError(errInvalidInput, "missing view command, use save, ls, rm or show")
+	}
+	switch args[0] {
+	case "ls", "list":
+		for _, name := range viewNames(state) {
+			view := state.Views[name]
+			fmt.Printf("%v:\n  %v\n", name, strings.ReplaceAll(view.String(), "\n", "\n  "))
+		}
+		return nil
+	}
+	if len(args) < 2 {
+		return newError(errInvalidInput, "missing view name")
+	}
+	name := args[1]
+	switch args[0] {
+	case "save":
+		if isCommand(name) {
+			return newError(errInvalidInput, "%v is a ghp command, choose another name", name)
+		}
+		view := savedView{
+			Filters:        filters,
+			Columns:        opts.columns,
+			ExcludeColumns: opts.excludeColumns,
+			Sort:           opts.sortBy,
+			GroupBy:        opts.groupBy,
+		}
+		if state.Views == nil {
+			state.Views = make(map[string]savedView)
+		}
+		state.Views[name] = view
+		err := state.save()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved view %v, run it with 'ghp %v' or 'ghp list -v %v'\n", name, name, name)
+	case "rm", "delete":
+		_, exists := state.Views[name]
+		if !exists {
+			return newError(errNotFound, "no view named %v", name)
+		}
+		delete(state.Views, name)
+		return state.save()
+	case "show":
+		view, exists := state.Views[name]
+		if !exists {
+			return newError(errNotFound, "no view named %v", name)
+		}
+		fmt.Println(view.String())
+	default:
+		return newError(errInvalidInput, "unknown view command %v, use save, ls, rm or show", args[0])
+	}
+	return nil
+}
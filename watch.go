@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 30 * time.Second
+	minWatchInterval     = 5 * time.Second
+	maxChangeLog         = 10
+)
+
+// watchFlag is -watch, the refresh interval can be given as -watch=1m or as
+// the argument following the flag
+type watchFlag struct {
+	enabled  bool
+	interval time.Duration
+}
+
+func (w *watchFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (w *watchFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err == nil {
+		w.enabled = enabled
+		return nil
+	}
+	return w.setInterval(value)
+}
+
+func (w *watchFlag) String() string {
+	if w == nil || !w.enabled {
+		return ""
+	}
+	return w.interval.String()
+}
+
+func (w *watchFlag) setInterval(value string) error {
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid watch interval %v", value)
+	}
+	if interval < minWatchInterval {
+		return fmt.Errorf("watch interval must be at least %v", minWatchInterval)
+	}
+	w.enabled = true
+	w.interval = interval
+	return nil
+}
+
+// doWatch lists the project again every interval, redrawing in place and
+// marking the cards changed since the previous refresh. It runs until
+// interrupted
+func doWatch(state ghpConfig, cache *appCache, client *ghpClient, f filterFlags, opts *listOptions, interval time.Duration) error {
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+	ctx := *client.context
+	filters := f.toFilters(state.User)
+	terminal := isTerminal(os.Stdout)
+	var board *ProjectProxy
+	var previous []cardState
+	changeLog := []string{}
+	for {
+		p, err := loadProject(state, cache, client, opts)
+		now := time.Now().Format("15:04:05")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && board == nil {
+			return err
+		}
+		if err != nil {
+			changeLog = append(changeLog, now+" refresh failed: "+err.Error())
+		} else {
+			board = p
+			current := boardStates(p)
+			opts.highlight = make(map[string]string)
+			if previous != nil {
+				for _, change := range diffBoards(previous, current) {
+					if _, marked := opts.highlight[change.card.URL]; !marked {
+						opts.highlight[change.card.URL] = change.kind
+					}
+					changeLog = append(changeLog, now+" "+change.String())
+				}
+			}
+			previous = current
+		}
+		if len(changeLog) > maxChangeLog {
+			changeLog = changeLog[len(changeLog)-maxChangeLog:]
+		}
+
+		if terminal {
+			fmt.Print("\x1b[H\x1b[2J")
+		}
+		header := fmt.Sprintf("%v, refreshed at %v every %v, Ctrl-C to stop", state.DefaultProject, now, interval)
+		if len(f) != 0 {
+			header += "\nfilters: " + f.String()
+		}
+		fmt.Println(singleColorHub.styled(singleColorHub.theme.Muted, header))
+		fancyList(board, filters, opts)
+		fmt.Println()
+		if len(changeLog) == 0 {
+			fmt.Println(singleColorHub.styled(singleColorHub.theme.Muted, "no changes yet"))
+		}
+		for _, line := range changeLog {
+			fmt.Println(singleColorHub.styled(singleColorHub.theme.Muted, line))
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wipSuffix is a wip limit at the end of a column name, like "In progress (5)"
+var wipSuffix = regexp.MustCompile(`\((\d+)\)\s*$`)
+
+// wipLimit returns the wip limit of a column, 0 for none. Limits in config
+// are found by column name or prefix and win over the column name suffix
+func wipLimit(state ghpConfig, name string) int {
+	keys := []string{}
+	for key := range state.WIPLimits {
+		if strings.EqualFold(key, name) {
+			return state.WIPLimits[key]
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if matchColumn(name, []string{key}) {
+			return state.WIPLimits[key]
+		}
+	}
+	match := wipSuffix.FindStringSubmatch(name)
+	if match == nil {
+		return 0
+	}
+	limit, _ := strconv.Atoi(match[1])
+	return limit
+}
+
+// findCard looks for a card by issue ref, "#number" when there is only one,
+// issue url or card id
+func findCard(p *ProjectProxy, ref string) (card, *column, error) {
+	found := []int{}
+	var match card
+	for n, col := range p.columns {
+		for _, c := range col.cards {
+			matches := lastPathElement(c.getURL()) == ref
+			if i := cardIssue(c); i != nil {
+				matches = matches || strings.EqualFold(i.ref(), ref) || i.ghIssue.GetHTMLURL() == ref ||
+					i.ghIssue.GetURL() == ref || (strings.HasPrefix(ref, "#") && strings.HasSuffix(i.ref(), ref))
+			}
+			if matches {
+				found = append(found, n)
+				match = c
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, nil, newError(errNotFound, "no card %v in the project", ref)
+	case 1:
+		return match, &p.columns[found[0]], nil
+	}
+	return nil, nil, newError(errInvalidInput, "%v matches %v cards, use repo#number", ref, len(found))
+}
+
+// findColumn looks for a column by name or unique prefix, ignoring case
+func findColumn(p *ProjectProxy, name string) (*column, error) {
+	found := []int{}
+	names := []string{}
+	for n, col := range p.columns {
+		if strings.EqualFold(col.name, name) {
+			return &p.columns[n], nil
+		}
+		if matchColumn(col.name, []string{name}) {
+			found = append(found, n)
+		}
+		names = append(names, col.name)
+	}
+	switch len(found) {
+	case 0:
+		return nil, newError(errNotFound, "no column %v, the columns are %v", name, strings.Join(names, ", "))
+	case 1:
+		return &p.columns[found[0]], nil
+	}
+	return nil, newError(errInvalidInput, "column %v is ambiguous, the columns are %v", name, strings.Join(names, ", "))
+}
+
+// cardName is the ref of issue cards or the beginning of notes
+func cardName(c card) string {
+	if i := cardIssue(c); i != nil {
+		return i.ref()
+	}
+	return "note " + strconv.Quote(ellipseStr(cardTitle(c), 30))
+}
+
+// wipExceeded tells why moving c from one column to another puts it over
+// its wip limit, empty when it doesn't
+func wipExceeded(c card, from, to *column) string {
+	if to == from || to.limit == 0 || cardIssue(c) == nil || to.wip() < to.limit {
+		return ""
+	}
+	return fmt.Sprintf("moving %v puts %v over its wip limit, %v/%v", cardName(c), to.name, to.wip()+1, to.limit)
+}
+
+// moveOptions are the move flags
+type moveOptions struct {
+	position string
+	force    bool
+}
+
+func doMove(env *cmdEnv, opts *moveOptions, args []string) error {
+	if len(args) != 2 {
+		return newError(errInvalidInput, "use 'ghp move <card> <column>'")
+	}
+	if opts.position != "top" && opts.position != "bottom" {
+		return newError(errInvalidInput, "invalid position %v, use top or bottom", opts.position)
+	}
+	err := checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	p, err := loadProject(*env.state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	c, from, err := findCard(p, args[0])
+	if err != nil {
+		return err
+	}
+	to, err := findColumn(p, args[1])
+	if err != nil {
+		return err
+	}
+	if msg := wipExceeded(c, from, to); msg != "" {
+		if !opts.force {
+			return newError(errCheckFailed, "%v, use -force to move it anyway", msg)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", msg)
+	}
+	id, err := cardID(c)
+	if err != nil {
+		return err
+	}
+	err = env.client.moveCard(id, opts.position, to.id)
+	if err != nil {
+		return err
+	}
+	wip := to.wip()
+	if to != from && cardIssue(c) != nil {
+		wip++
+	}
+	if to.limit > 0 {
+		fmt.Printf("Moved %v from %v to %v (%v/%v)\n", cardName(c), from.name, to.name, wip, to.limit)
+	} else {
+		fmt.Printf("Moved %v from %v to %v\n", cardName(c), from.name, to.name)
+	}
+	return nil
+}
+
+// doCheckWIP prints the columns with a wip limit and fails when any is over
+func doCheckWIP(env *cmdEnv) error {
+	err := checkAllConfig(env.state, env.client)
+	if err != nil {
+		return err
+	}
+	p, err := loadProject(*env.state, env.cache, env.client, env.opts)
+	if err != nil {
+		return err
+	}
+	over, limited := 0, 0
+	for n := range p.columns {
+		col := &p.columns[n]
+		if col.limit == 0 {
+			continue
+		}
+		limited++
+		status := singleColorHub.styled(singleColorHub.theme.Ok, "ok")
+		if col.overLimit() {
+			over++
+			status = singleColorHub.styled(singleColorHub.theme.Fail, fmt.Sprintf("over by %v", col.wip()-col.limit))
+		}
+		fmt.Printf("%v %v/%v %v\n", col.name, col.wip(), col.limit, status)
+	}
+	if limited == 0 {
+		fmt.Println(`No wip limits, set wip_limits in config or end column names with the limit like "In progress (5)"`)
+	}
+	if over != 0 {
+		return newError(errCheckFailed, "%v columns over their wip limit", over)
+	}
+	return nil
+}
+
+func init() {
+	opts := &moveOptions{}
+	registerCommand(&ghpCommand{
+		name:    "move",
+		args:    "<card> <column>",
+		summary: "Move a card to another column",
+		description: `
+Cards are given as repo#number, #number when only one repository has it, the
+issue url or the card id, and columns by name or unique prefix. Moving an
+issue or pull request into a column at its wip limit is refused unless -force
+is given.`,
+		examples: []string{
+			"ghp move api#12 'In progress'",
+			"ghp move '#12' review -position bottom",
+			"ghp move api#12 doing -force",
+		},
+		complete: []string{"@refs", "@columns"},
+		flags: func(fs *flag.FlagSet, env *cmdEnv) {
+			fs.StringVar(&opts.position, "position", "top", "Place the card at the top or bottom of the column")
+			fs.BoolVar(&opts.force, "force", false, "Move the card even over the wip limit")
+		},
+		run: func(env *cmdEnv, args []string) error {
+			return doMove(env, opts, args)
+		},
+	})
+	registerCommand(&ghpCommand{
+		name:    "check",
+		args:    "wip",
+		summary: "Check the board for problems, for scheduled jobs",
+		description: `
+'ghp check wip' lists the columns with a wip limit and exits with code 7 when
+any of them has more issues and pull requests than its limit. Limits come from
+wip_limits in config or from column names ending in the limit, like
+"In progress (5)".`,
+		examples: []string{"ghp check wip -quiet || notify-team"},
+		complete: []string{"wip"},
+		run: func(env *cmdEnv, args []string) error {
+			if len(args) != 1 || args[0] != "wip" {
+				return newError(errInvalidInput, "use 'ghp check wip'")
+			}
+			return doCheckWIP(env)
+		},
+	})
+}
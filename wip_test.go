@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestWIPLimit(t *testing.T) {
+	config := ghpConfig{WIPLimits: map[string]int{"Review": 2, "in": 4, "In progress (5)": 3}}
+	tests := []struct {
+		name  string
+		state ghpConfig
+		want  int
+	}{
+		{"In progress (5)", ghpConfig{}, 5},
+		{"In progress (12)  ", ghpConfig{}, 12},
+		{"In progress", ghpConfig{}, 0},
+		{"(3) In progress", ghpConfig{}, 0},
+		{"In progress (x)", ghpConfig{}, 0},
+		{"In progress 5", ghpConfig{}, 0},
+		{"review", config, 2},
+		{"Review (7)", config, 2},
+		{"In progress (5)", config, 3},
+		{"Inbox (9)", config, 4},
+		{"Done (1)", config, 1},
+	}
+	for _, test := range tests {
+		if got := wipLimit(test.state, test.name); got != test.want {
+			t.Errorf("%q: got limit %v, want %v", test.name, got, test.want)
+		}
+	}
+}